@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 
+	"github.com/elijahglover/inbound/internal/acme"
 	"github.com/elijahglover/inbound/internal/config"
 	"github.com/elijahglover/inbound/internal/controller"
 	"github.com/elijahglover/inbound/internal/logger"
@@ -59,8 +61,23 @@ func execute(ctx context.Context) error {
 	// K8s Resource/Controller Watcher
 	controllerComp := controller.New(loggerComp, configComp.TargetNamespace, k8sClient)
 	go controllerComp.Monitor(ctx)
+	if configComp.DefaultBackendService != "" {
+		parts := strings.SplitN(configComp.DefaultBackendService, "/", 2)
+		controllerComp.MonitorDefaultBackend(ctx, parts[0], parts[1], int32(configComp.DefaultBackendPort))
+	}
+	if configComp.ErrorPagesConfigMap != "" {
+		parts := strings.SplitN(configComp.ErrorPagesConfigMap, "/", 2)
+		controllerComp.MonitorErrorPages(ctx, parts[0], parts[1])
+	}
+
+	// ACME certificate issuance
+	var acmeComp *acme.Manager
+	if configComp.ACMEEnabled {
+		acmeComp = acme.New(loggerComp, configComp, k8sClient, controllerComp)
+		go acmeComp.Run(ctx)
+	}
 
-	serverComp := server.New(loggerComp, configComp, controllerComp)
+	serverComp := server.New(loggerComp, configComp, controllerComp, acmeComp)
 	return serverComp.Start(ctx)
 }
 
@@ -0,0 +1,186 @@
+package accesslog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// FormatCombined Apache combined log format
+	FormatCombined = "combined"
+	// FormatJSON one JSON object per line
+	FormatJSON = "json"
+	// FormatLogfmt key=value pairs
+	FormatLogfmt = "logfmt"
+)
+
+// Entry represents a single completed request
+type Entry struct {
+	Time       time.Time     `json:"time"`
+	ClientIP   string        `json:"client_ip"`
+	Host       string        `json:"host"`
+	Method     string        `json:"method"`
+	Path       string        `json:"path"`
+	Protocol   string        `json:"protocol"`
+	Status     int           `json:"status"`
+	Bytes      int64         `json:"bytes"`
+	Duration   time.Duration `json:"-"`
+	Upstream   string        `json:"upstream"`
+	Ingress    string        `json:"ingress"`
+	TLSVersion string        `json:"tls_version"`
+	Referer    string        `json:"referer"`
+	UserAgent  string        `json:"user_agent"`
+}
+
+// Logger writes access log entries
+type Logger struct {
+	format     string
+	sampleRate float64
+	out        io.Writer
+	channel    chan Entry
+}
+
+// New access logger writing to out, sample rate between 0 and 1
+func New(format string, sampleRate float64, out io.Writer) *Logger {
+	l := &Logger{
+		format:     format,
+		sampleRate: sampleRate,
+		out:        out,
+		channel:    make(chan Entry, 1024),
+	}
+	go l.output()
+	return l
+}
+
+// Log queues entry to be written, subject to sampling
+// Entries are dropped rather than blocking requests when output can't keep up, returns false when dropped
+func (l *Logger) Log(entry Entry) bool {
+	if l.sampleRate < 1 && rand.Float64() >= l.sampleRate {
+		return true
+	}
+	select {
+	case l.channel <- entry:
+		return true
+	default:
+		return false
+	}
+}
+
+func (l *Logger) output() {
+	for {
+		entry := <-l.channel
+		fmt.Fprintln(l.out, Format(l.format, entry))
+	}
+}
+
+// Format renders entry, unknown formats use combined
+func Format(format string, entry Entry) string {
+	switch format {
+	case FormatJSON:
+		return formatJSON(entry)
+	case FormatLogfmt:
+		return formatLogfmt(entry)
+	default:
+		return formatCombined(entry)
+	}
+}
+
+// formatCombined renders Apache combined format followed by key=value fields for the proxy
+func formatCombined(entry Entry) string {
+	bytes := "-"
+	if entry.Bytes > 0 {
+		bytes = strconv.FormatInt(entry.Bytes, 10)
+	}
+	return fmt.Sprintf(`%s - - [%s] "%s %s %s" %v %s "%s" "%s" host=%s duration_ms=%s upstream=%s ingress=%s tls_version=%s`,
+		dash(entry.ClientIP),
+		entry.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		escape(entry.Method),
+		escape(entry.Path),
+		escape(entry.Protocol),
+		entry.Status,
+		bytes,
+		dash(escape(entry.Referer)),
+		dash(escape(entry.UserAgent)),
+		dash(escape(entry.Host)),
+		strconv.FormatFloat(durationMs(entry.Duration), 'f', 3, 64),
+		dash(entry.Upstream),
+		dash(entry.Ingress),
+		dash(entry.TLSVersion),
+	)
+}
+
+func formatJSON(entry Entry) string {
+	raw, _ := json.Marshal(struct {
+		Entry
+		DurationMs float64 `json:"duration_ms"`
+	}{
+		Entry:      entry,
+		DurationMs: durationMs(entry.Duration),
+	})
+	return string(raw)
+}
+
+func formatLogfmt(entry Entry) string {
+	pairs := [][2]string{
+		{"time", entry.Time.Format(time.RFC3339)},
+		{"client_ip", entry.ClientIP},
+		{"host", entry.Host},
+		{"method", entry.Method},
+		{"path", entry.Path},
+		{"protocol", entry.Protocol},
+		{"status", strconv.Itoa(entry.Status)},
+		{"bytes", strconv.FormatInt(entry.Bytes, 10)},
+		{"duration_ms", strconv.FormatFloat(durationMs(entry.Duration), 'f', 3, 64)},
+		{"upstream", entry.Upstream},
+		{"ingress", entry.Ingress},
+		{"tls_version", entry.TLSVersion},
+		{"referer", entry.Referer},
+		{"user_agent", entry.UserAgent},
+	}
+
+	parts := make([]string, len(pairs))
+	for i, pair := range pairs {
+		parts[i] = pair[0] + "=" + logfmtValue(pair[1])
+	}
+	return strings.Join(parts, " ")
+}
+
+func logfmtValue(value string) string {
+	if value == "" {
+		return `""`
+	}
+	if strings.ContainsAny(value, " \"=\\\n\t") {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+func durationMs(duration time.Duration) float64 {
+	return float64(duration) / float64(time.Millisecond)
+}
+
+// escape hex encodes quotes, backslashes and control characters like nginx so client supplied values can't forge fields
+func escape(value string) string {
+	var escaped strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c == '"' || c == '\\' || c < 0x20 || c == 0x7f {
+			fmt.Fprintf(&escaped, `\x%02X`, c)
+			continue
+		}
+		escaped.WriteByte(c)
+	}
+	return escaped.String()
+}
+
+func dash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,138 @@
+package accesslog_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elijahglover/inbound/internal/accesslog"
+)
+
+var entry = accesslog.Entry{
+	Time:      time.Date(2019, time.January, 2, 15, 4, 5, 0, time.UTC),
+	ClientIP:  "192.0.2.1",
+	Host:      "example.com",
+	Method:    "GET",
+	Path:      "/api?q=1",
+	Protocol:  "HTTP/1.1",
+	Status:    200,
+	Bytes:     512,
+	Duration:  1500 * time.Microsecond,
+	Upstream:  "10.0.0.1:80",
+	Ingress:   "default/api",
+	UserAgent: "curl/7.54.0",
+}
+
+func Test_Format_Combined(t *testing.T) {
+	secure := entry
+	secure.TLSVersion = "TLSv1.2"
+	expected := `192.0.2.1 - - [02/Jan/2019:15:04:05 +0000] "GET /api?q=1 HTTP/1.1" 200 512 "-" "curl/7.54.0" host=example.com duration_ms=1.500 upstream=10.0.0.1:80 ingress=default/api tls_version=TLSv1.2`
+	if actual := accesslog.Format(accesslog.FormatCombined, secure); actual != expected {
+		t.Fatalf("unexpected output %s", actual)
+	}
+
+	empty := entry
+	empty.Bytes = 0
+	empty.UserAgent = ""
+	empty.Upstream = ""
+	empty.Ingress = ""
+	expected = `192.0.2.1 - - [02/Jan/2019:15:04:05 +0000] "GET /api?q=1 HTTP/1.1" 200 - "-" "-" host=example.com duration_ms=1.500 upstream=- ingress=- tls_version=-`
+	if actual := accesslog.Format("unknown", empty); actual != expected {
+		t.Fatalf("unexpected output %s", actual)
+	}
+}
+
+func Test_Format_Combined_Escapes_Client_Values(t *testing.T) {
+	cases := []struct {
+		method    string
+		path      string
+		userAgent string
+		expected  string
+	}{
+		{"GET", "/api", `agent" 500 "forged`, `"GET /api HTTP/1.1" 200 512 "-" "agent\x22 500 \x22forged"`},
+		{"GET", "/api\"\n127.0.0.1 - -", "curl", `"GET /api\x22\x0A127.0.0.1 - - HTTP/1.1" 200 512 "-" "curl"`},
+		{"GET\"", "/", "back\\slash\ttab\x7f", `"GET\x22 / HTTP/1.1" 200 512 "-" "back\x5Cslash\x09tab\x7F"`},
+		{"GET", "/café", "curl", `"GET /café HTTP/1.1" 200 512 "-" "curl"`},
+	}
+	for _, c := range cases {
+		escaped := entry
+		escaped.Method = c.method
+		escaped.Path = c.path
+		escaped.UserAgent = c.userAgent
+
+		actual := accesslog.Format(accesslog.FormatCombined, escaped)
+		if strings.Contains(actual, "\n") || !strings.Contains(actual, c.expected+" host=example.com ") {
+			t.Fatalf("unexpected output %s", actual)
+		}
+	}
+}
+
+func Test_Format_JSON(t *testing.T) {
+	var actual map[string]interface{}
+	if err := json.Unmarshal([]byte(accesslog.Format(accesslog.FormatJSON, entry)), &actual); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	for key, expected := range map[string]interface{}{
+		"client_ip":   "192.0.2.1",
+		"path":        "/api?q=1",
+		"status":      float64(200),
+		"bytes":       float64(512),
+		"duration_ms": 1.5,
+		"ingress":     "default/api",
+		"referer":     "",
+	} {
+		if actual[key] != expected {
+			t.Fatalf("unexpected output for %s %v %v", key, actual[key], expected)
+		}
+	}
+}
+
+func Test_Format_Logfmt(t *testing.T) {
+	expected := `time=2019-01-02T15:04:05Z client_ip=192.0.2.1 host=example.com method=GET path="/api?q=1" protocol=HTTP/1.1 status=200 bytes=512 duration_ms=1.500 upstream=10.0.0.1:80 ingress=default/api tls_version="" referer="" user_agent=curl/7.54.0`
+	if actual := accesslog.Format(accesslog.FormatLogfmt, entry); actual != expected {
+		t.Fatalf("unexpected output %s", actual)
+	}
+
+	quoted := entry
+	quoted.UserAgent = `Mozilla/5.0 "test"`
+	if actual := accesslog.Format(accesslog.FormatLogfmt, quoted); !strings.HasSuffix(actual, ` user_agent="Mozilla/5.0 \"test\""`) {
+		t.Fatalf("unexpected output %s", actual)
+	}
+}
+
+type blockingWriter struct {
+	release chan struct{}
+}
+
+func (w *blockingWriter) Write(b []byte) (int, error) {
+	<-w.release
+	return len(b), nil
+}
+
+func Test_Logger_Drops_When_Output_Blocked(t *testing.T) {
+	out := &blockingWriter{release: make(chan struct{})}
+	defer close(out.release)
+	logger := accesslog.New(accesslog.FormatCombined, 1, out)
+
+	done := make(chan int)
+	go func() {
+		dropped := 0
+		for i := 0; i < 2048; i++ {
+			if !logger.Log(entry) {
+				dropped++
+			}
+		}
+		done <- dropped
+	}()
+
+	select {
+	case dropped := <-done:
+		if dropped == 0 {
+			t.Fatalf("expected entries to be dropped")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("log blocked on output")
+	}
+}
@@ -0,0 +1,93 @@
+package acme
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"github.com/elijahglover/inbound/internal/helpers"
+	"golang.org/x/crypto/acme"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const accountKey = "acme-account.key"
+
+func (m *Manager) register(ctx context.Context) error {
+	key, err := m.accountKey()
+	if err != nil {
+		return err
+	}
+	m.acmeClient.Key = key
+
+	account := &acme.Account{}
+	if m.config.ACMEEmail != "" {
+		account.Contact = []string{"mailto:" + m.config.ACMEEmail}
+	}
+
+	_, err = m.acmeClient.Register(ctx, account, acme.AcceptTOS)
+	if err != nil && err != acme.ErrAccountAlreadyExists {
+		return err
+	}
+	m.logger.Infof("Registered ACME account with %s", m.config.ACMEDirectoryURL)
+	return nil
+}
+
+// accountKey loads the account key from the configured secret, creating it when missing
+func (m *Manager) accountKey() (crypto.Signer, error) {
+	if m.config.ACMEAccountSecret == "" {
+		m.logger.Warning("No ACME account secret configured, using a new account key")
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
+
+	parts := strings.SplitN(m.config.ACMEAccountSecret, "/", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("ACME account secret %s must be in namespace/name format", m.config.ACMEAccountSecret)
+	}
+	secrets := m.secrets(parts[0])
+
+	secret, err := secrets.Get(parts[1], meta_v1.GetOptions{})
+	if err == nil {
+		block, _ := pem.Decode(secret.Data[accountKey])
+		if block == nil {
+			return nil, fmt.Errorf("Missing %s from secret %s", accountKey, m.config.ACMEAccountSecret)
+		}
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	if !errors.IsNotFound(err) {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	keyRaw, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	keyPem, err := helpers.EncodePem("EC PRIVATE KEY", keyRaw)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = secrets.Create(&v1.Secret{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      parts[1],
+			Namespace: parts[0],
+		},
+		Data: map[string][]byte{accountKey: keyPem},
+	})
+	if err != nil {
+		return nil, err
+	}
+	m.logger.Infof("Created ACME account key in secret %s", m.config.ACMEAccountSecret)
+	return key, nil
+}
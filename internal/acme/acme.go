@@ -0,0 +1,201 @@
+package acme
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elijahglover/inbound/internal/config"
+	"github.com/elijahglover/inbound/internal/controller"
+	"github.com/elijahglover/inbound/internal/logger"
+	"golang.org/x/crypto/acme"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ChallengeURLPrefix is the path HTTP-01 challenges are served on
+const ChallengeURLPrefix = "/.well-known/acme-challenge/"
+
+const (
+	reconcileInterval = time.Minute
+	// Failed issuance is retried with exponential backoff between these bounds
+	retryIntervalMin = 5 * time.Minute
+	retryIntervalMax = 24 * time.Hour
+)
+
+// attempt tracks failed issuance for backoff
+type attempt struct {
+	failures int
+	next     time.Time
+}
+
+// tlsSecretSource provides TLS secrets referenced by ingresses and their loaded certificates
+type tlsSecretSource interface {
+	GetTLSSecrets() []*controller.TLSSecret
+	GetSecretCertificate(namespace string, secretName string) *tls.Certificate
+}
+
+// secretClient reads and writes secrets in a namespace
+type secretClient interface {
+	Get(name string, options meta_v1.GetOptions) (*v1.Secret, error)
+	Create(secret *v1.Secret) (*v1.Secret, error)
+	Update(secret *v1.Secret) (*v1.Secret, error)
+}
+
+// Manager issues certificates for ingress TLS secrets and answers HTTP-01 challenges
+type Manager struct {
+	logger     logger.Logger
+	config     *config.Config
+	secrets    func(namespace string) secretClient
+	controller tlsSecretSource
+	acmeClient *acme.Client
+	registered bool
+	// HTTP-01 challenge responses - key = token, value is key authorization
+	tokens     map[string]string
+	tokensLock *sync.Mutex
+	// Failed issuance per secret - key = secret name
+	attempts map[string]*attempt
+	// Unmanaged secrets already warned about expiry - key = secret name
+	expiryWarned map[string]bool
+}
+
+// New ACME manager
+func New(logger logger.Logger, config *config.Config, client *kubernetes.Clientset, controller *controller.Controller) *Manager {
+	httpClient := &http.Client{Timeout: 30 * time.Second}
+	if config.ACMEInsecureSkipVerify {
+		httpClient.Transport = &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}
+	}
+
+	return &Manager{
+		logger: logger,
+		config: config,
+		secrets: func(namespace string) secretClient {
+			return client.Core().Secrets(namespace)
+		},
+		controller: controller,
+		acmeClient: &acme.Client{
+			DirectoryURL: config.ACMEDirectoryURL,
+			HTTPClient:   httpClient,
+			UserAgent:    "inbound",
+		},
+		tokens:       map[string]string{},
+		tokensLock:   &sync.Mutex{},
+		attempts:     map[string]*attempt{},
+		expiryWarned: map[string]bool{},
+	}
+}
+
+// Run issues missing certificates until context is cancelled
+func (m *Manager) Run(ctx context.Context) {
+	m.logger.Infof("Issuing certificates using ACME directory %s", m.config.ACMEDirectoryURL)
+	ticker := time.NewTicker(reconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		m.reconcile(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// HandleChallenge responds to HTTP-01 challenge requests, returns false if token is unknown
+func (m *Manager) HandleChallenge(w http.ResponseWriter, req *http.Request) bool {
+	if !strings.HasPrefix(req.URL.Path, ChallengeURLPrefix) {
+		return false
+	}
+	token := strings.TrimPrefix(req.URL.Path, ChallengeURLPrefix)
+
+	m.tokensLock.Lock()
+	keyAuthorization, ok := m.tokens[token]
+	m.tokensLock.Unlock()
+	if !ok {
+		return false
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(200)
+	w.Write([]byte(keyAuthorization))
+	return true
+}
+
+func (m *Manager) reconcile(ctx context.Context) {
+	if !m.registered {
+		if err := m.register(ctx); err != nil {
+			m.logger.Errorf("Unable to register ACME account %s", err)
+			return
+		}
+		m.registered = true
+	}
+
+	for _, secret := range m.controller.GetTLSSecrets() {
+		if ctx.Err() != nil {
+			return
+		}
+
+		key := secret.Namespace + "/" + secret.SecretName
+		if previous, ok := m.attempts[key]; ok && time.Now().Before(previous.next) {
+			continue
+		}
+
+		reason, err := m.requiresCertificate(secret)
+		if err != nil {
+			m.logger.Errorf("Unable to check secret %s %s", key, err)
+			continue
+		}
+		if reason == "" {
+			continue
+		}
+
+		m.logger.Infof("Issuing certificate for %s with hosts %s, %s", key, strings.Join(secret.Hosts, ", "), reason)
+		err = m.issue(ctx, secret)
+		if err != nil {
+			delay := m.recordFailure(key)
+			m.logger.Errorf("Unable to issue certificate for %s, retrying in %s %s", key, delay, err)
+			continue
+		}
+		delete(m.attempts, key)
+		m.logger.Infof("Issued certificate for %s", key)
+	}
+}
+
+// recordFailure schedules the next attempt doubling the delay after each failure
+func (m *Manager) recordFailure(key string) time.Duration {
+	previous, ok := m.attempts[key]
+	if !ok {
+		previous = &attempt{}
+		m.attempts[key] = previous
+	}
+	previous.failures++
+
+	delay := retryIntervalMin
+	for i := 1; i < previous.failures && delay < retryIntervalMax; i++ {
+		delay *= 2
+	}
+	if delay > retryIntervalMax {
+		delay = retryIntervalMax
+	}
+	previous.next = time.Now().Add(delay)
+	return delay
+}
+
+func (m *Manager) setToken(token string, keyAuthorization string) {
+	m.tokensLock.Lock()
+	defer m.tokensLock.Unlock()
+	m.tokens[token] = keyAuthorization
+}
+
+func (m *Manager) deleteToken(token string) {
+	m.tokensLock.Lock()
+	defer m.tokensLock.Unlock()
+	delete(m.tokens, token)
+}
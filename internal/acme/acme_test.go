@@ -0,0 +1,478 @@
+package acme
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elijahglover/inbound/internal/config"
+	"github.com/elijahglover/inbound/internal/controller"
+	"github.com/elijahglover/inbound/internal/logger"
+	"golang.org/x/crypto/acme"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// fakeSecrets stores secrets in memory, key = namespace/name
+type fakeSecrets struct {
+	secrets map[string]*v1.Secret
+	gets    int
+}
+
+type fakeSecretClient struct {
+	store     *fakeSecrets
+	namespace string
+}
+
+func (c *fakeSecretClient) Get(name string, options meta_v1.GetOptions) (*v1.Secret, error) {
+	c.store.gets++
+	secret, ok := c.store.secrets[c.namespace+"/"+name]
+	if !ok {
+		return nil, errors.NewNotFound(schema.GroupResource{Resource: "secrets"}, name)
+	}
+	return secret.DeepCopy(), nil
+}
+
+func (c *fakeSecretClient) Create(secret *v1.Secret) (*v1.Secret, error) {
+	c.store.secrets[c.namespace+"/"+secret.Name] = secret
+	return secret, nil
+}
+
+func (c *fakeSecretClient) Update(secret *v1.Secret) (*v1.Secret, error) {
+	c.store.secrets[c.namespace+"/"+secret.Name] = secret
+	return secret, nil
+}
+
+// fakeTLSSecrets serves TLS secrets and certificates loaded by the certificate watcher
+type fakeTLSSecrets struct {
+	secrets      []*controller.TLSSecret
+	certificates map[string]*tls.Certificate
+}
+
+func (f *fakeTLSSecrets) GetTLSSecrets() []*controller.TLSSecret {
+	return f.secrets
+}
+
+func (f *fakeTLSSecrets) GetSecretCertificate(namespace string, secretName string) *tls.Certificate {
+	return f.certificates[namespace+"/"+secretName]
+}
+
+func newTestManager(directoryURL string, tlsSecrets *fakeTLSSecrets) (*Manager, *fakeSecrets) {
+	store := &fakeSecrets{secrets: map[string]*v1.Secret{}}
+	m := New(logger.NewNull(), &config.Config{ACMEDirectoryURL: directoryURL, ACMERenewBefore: 30 * 24 * time.Hour}, nil, nil)
+	m.controller = tlsSecrets
+	m.secrets = func(namespace string) secretClient {
+		return &fakeSecretClient{store: store, namespace: namespace}
+	}
+	return m, store
+}
+
+// testCertificate returns a self signed certificate and key for hosts in PEM format
+func testCertificate(t *testing.T, hosts []string, notAfter time.Time) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: hosts[0]},
+		DNSNames:     hosts,
+		NotBefore:    notAfter.Add(-90 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	certificateRaw, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	keyRaw, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificateRaw}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyRaw})
+}
+
+// fakeDirectory is a minimal RFC 8555 server issuing certificates once HTTP-01 challenges are served
+type fakeDirectory struct {
+	server       *httptest.Server
+	manager      *Manager
+	rejectOrders bool
+	orders       int
+	lock         *sync.Mutex
+	identifiers  []string
+	validated    map[int]bool
+	certificate  []byte
+}
+
+func newFakeDirectory() *fakeDirectory {
+	d := &fakeDirectory{lock: &sync.Mutex{}, validated: map[int]bool{}}
+	d.server = httptest.NewServer(d)
+	return d
+}
+
+func (d *fakeDirectory) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	base := d.server.URL
+	w.Header().Set("Replay-Nonce", "nonce")
+	w.Header().Set("Content-Type", "application/json")
+
+	// Signatures aren't checked, only the payload is used
+	var body struct {
+		Payload string `json:"payload"`
+	}
+	var payload []byte
+	if req.Method == http.MethodPost {
+		json.NewDecoder(req.Body).Decode(&body)
+		payload, _ = base64.RawURLEncoding.DecodeString(body.Payload)
+	}
+
+	var index int
+	switch {
+	case req.URL.Path == "/directory":
+		json.NewEncoder(w).Encode(map[string]string{"newNonce": base + "/nonce", "newAccount": base + "/account", "newOrder": base + "/order"})
+	case req.URL.Path == "/nonce":
+	case req.URL.Path == "/account":
+		w.Header().Set("Location", base+"/account/1")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"status": acme.StatusValid})
+	case req.URL.Path == "/order":
+		d.orders++
+		if d.rejectOrders {
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]string{"type": "urn:ietf:params:acme:error:unauthorized", "detail": "rejected"})
+			return
+		}
+		var order struct {
+			Identifiers []struct{ Value string }
+		}
+		json.Unmarshal(payload, &order)
+		d.identifiers = nil
+		for _, identifier := range order.Identifiers {
+			d.identifiers = append(d.identifiers, identifier.Value)
+		}
+		w.Header().Set("Location", base+"/order/1")
+		w.WriteHeader(http.StatusCreated)
+		d.writeOrder(w)
+	case req.URL.Path == "/order/1":
+		w.Header().Set("Location", base+"/order/1")
+		d.writeOrder(w)
+	case parsePath(req.URL.Path, "/authz/%d", &index):
+		status := acme.StatusPending
+		if d.validated[index] {
+			status = acme.StatusValid
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":     status,
+			"identifier": map[string]string{"type": "dns", "value": d.identifiers[index]},
+			"challenges": []map[string]string{{"type": "http-01", "url": fmt.Sprintf("%s/challenge/%d", base, index), "token": fmt.Sprintf("token-%d", index), "status": status}},
+		})
+	case parsePath(req.URL.Path, "/challenge/%d", &index):
+		// Validate the challenge through the handler the server routes HTTP-01 requests to
+		token := fmt.Sprintf("token-%d", index)
+		expected, _ := d.manager.acmeClient.HTTP01ChallengeResponse(token)
+		recorder := httptest.NewRecorder()
+		challengeReq := httptest.NewRequest("GET", "http://"+d.identifiers[index]+ChallengeURLPrefix+token, nil)
+		if d.manager.HandleChallenge(recorder, challengeReq) && recorder.Body.String() == expected {
+			d.validated[index] = true
+		}
+		json.NewEncoder(w).Encode(map[string]string{"type": "http-01", "url": fmt.Sprintf("%s/challenge/%d", base, index), "token": token, "status": acme.StatusProcessing})
+	case req.URL.Path == "/finalize":
+		var finalize struct {
+			CSR string
+		}
+		json.Unmarshal(payload, &finalize)
+		csrRaw, _ := base64.RawURLEncoding.DecodeString(finalize.CSR)
+		csr, err := x509.ParseCertificateRequest(csrRaw)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(2),
+			Subject:      csr.Subject,
+			DNSNames:     csr.DNSNames,
+			NotBefore:    time.Now(),
+			NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+		}
+		caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		certificateRaw, _ := x509.CreateCertificate(rand.Reader, template, template, csr.PublicKey, caKey)
+		d.certificate = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificateRaw})
+		w.Header().Set("Location", base+"/order/1")
+		d.writeOrder(w)
+	case req.URL.Path == "/certificate":
+		w.Header().Set("Content-Type", "application/pem-certificate-chain")
+		w.Write(d.certificate)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// writeOrder writes the order status, lock must be held
+func (d *fakeDirectory) writeOrder(w http.ResponseWriter) {
+	base := d.server.URL
+	status := acme.StatusReady
+	authorizations := []string{}
+	for i := range d.identifiers {
+		authorizations = append(authorizations, fmt.Sprintf("%s/authz/%d", base, i))
+		if !d.validated[i] {
+			status = acme.StatusPending
+		}
+	}
+	order := map[string]interface{}{
+		"status":         status,
+		"authorizations": authorizations,
+		"finalize":       base + "/finalize",
+	}
+	if d.certificate != nil {
+		order["status"] = acme.StatusValid
+		order["certificate"] = base + "/certificate"
+	}
+	json.NewEncoder(w).Encode(order)
+}
+
+func parsePath(path string, format string, index *int) bool {
+	_, err := fmt.Sscanf(path, format, index)
+	return err == nil
+}
+
+func Test_Manager_reconcile_Issues_Certificate(t *testing.T) {
+	directory := newFakeDirectory()
+	defer directory.server.Close()
+
+	tlsSecrets := &fakeTLSSecrets{secrets: []*controller.TLSSecret{
+		{Namespace: "default", SecretName: "api-tls", Hosts: []string{"api.example.com", "*.example.com", "www.example.com"}},
+	}}
+	m, store := newTestManager(directory.server.URL+"/directory", tlsSecrets)
+	directory.manager = m
+
+	m.reconcile(context.Background())
+
+	// Wildcards can't be validated over HTTP-01
+	if strings.Join(directory.identifiers, ",") != "api.example.com,www.example.com" {
+		t.Fatalf("unexpected order identifiers %v", directory.identifiers)
+	}
+	if len(directory.validated) != 2 {
+		t.Fatalf("challenges not validated %v", directory.validated)
+	}
+	if len(m.tokens) != 0 {
+		t.Fatalf("challenge tokens not removed %v", m.tokens)
+	}
+
+	secret, ok := store.secrets["default/api-tls"]
+	if !ok {
+		t.Fatalf("secret not created")
+	}
+	if secret.Annotations[annotationIssued] != "true" {
+		t.Fatalf("issued secret not annotated %v", secret.Annotations)
+	}
+	certificate, err := tls.X509KeyPair(secret.Data[tlsCertificate], secret.Data[tlsPrivateKey])
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	leaf, _ := x509.ParseCertificate(certificate.Certificate[0])
+	for _, host := range []string{"api.example.com", "www.example.com"} {
+		if leaf.VerifyHostname(host) != nil {
+			t.Fatalf("certificate doesn't cover %s", host)
+		}
+	}
+	if _, ok := m.attempts["default/api-tls"]; ok {
+		t.Fatalf("successful issuance recorded as failure")
+	}
+}
+
+func Test_Manager_requiresCertificate(t *testing.T) {
+	hosts := []string{"a.example.com", "b.example.com"}
+	validCertificate, validKey := testCertificate(t, hosts, time.Now().Add(60*24*time.Hour))
+	partialCertificate, partialKey := testCertificate(t, hosts[:1], time.Now().Add(60*24*time.Hour))
+
+	cases := []struct {
+		name     string
+		acme     bool
+		hosts    []string
+		data     map[string][]byte
+		expected string
+	}{
+		{"missing", false, hosts, nil, "secret missing"},
+		{"missing acme", true, hosts, nil, "secret missing"},
+		{"empty acme", true, hosts, map[string][]byte{}, "secret has no certificate"},
+		{"empty", false, hosts, map[string][]byte{}, ""},
+		{"valid acme", true, hosts, map[string][]byte{tlsCertificate: validCertificate, tlsPrivateKey: validKey}, ""},
+		{"partial acme", true, hosts, map[string][]byte{tlsCertificate: partialCertificate, tlsPrivateKey: partialKey}, "certificate doesn't cover b.example.com"},
+		{"partial", false, hosts, map[string][]byte{tlsCertificate: partialCertificate, tlsPrivateKey: partialKey}, ""},
+		{"wildcard acme", true, []string{"a.example.com", "*.example.com"}, map[string][]byte{tlsCertificate: partialCertificate, tlsPrivateKey: partialKey}, ""},
+	}
+	for _, c := range cases {
+		m, store := newTestManager("", &fakeTLSSecrets{})
+		if c.data != nil {
+			store.secrets["default/tls"] = &v1.Secret{ObjectMeta: meta_v1.ObjectMeta{Name: "tls", Namespace: "default"}, Data: c.data}
+		}
+
+		reason, err := m.requiresCertificate(&controller.TLSSecret{Namespace: "default", SecretName: "tls", Hosts: c.hosts, ACME: c.acme})
+		if err != nil {
+			t.Fatalf("unexpected error for %s %s", c.name, err)
+		}
+		if reason != c.expected {
+			t.Fatalf("unexpected output for %s %s %s", c.name, reason, c.expected)
+		}
+	}
+}
+
+func Test_Manager_recordFailure(t *testing.T) {
+	cases := []struct {
+		failures int
+		expected time.Duration
+	}{
+		{1, 5 * time.Minute},
+		{2, 10 * time.Minute},
+		{3, 20 * time.Minute},
+		{5, 80 * time.Minute},
+		{9, 1280 * time.Minute},
+		{10, 24 * time.Hour},
+		{20, 24 * time.Hour},
+	}
+	for _, c := range cases {
+		m, _ := newTestManager("", &fakeTLSSecrets{})
+		var delay time.Duration
+		for i := 0; i < c.failures; i++ {
+			delay = m.recordFailure("default/tls")
+		}
+		if delay != c.expected {
+			t.Fatalf("unexpected delay after %v failures %s %s", c.failures, delay, c.expected)
+		}
+		if next := time.Until(m.attempts["default/tls"].next); next > c.expected || next < c.expected-time.Minute {
+			t.Fatalf("unexpected next attempt %s", next)
+		}
+
+		// Failures are tracked per secret
+		if delay := m.recordFailure("default/other-tls"); delay != retryIntervalMin {
+			t.Fatalf("unexpected delay for other secret %s", delay)
+		}
+	}
+}
+
+func Test_Manager_requiresCertificate_Renewal(t *testing.T) {
+	hosts := []string{"a.example.com"}
+	day := 24 * time.Hour
+
+	cases := []struct {
+		name      string
+		acme      bool
+		annotated bool
+		loaded    bool
+		expiresIn time.Duration
+		expected  string
+		gets      int
+		warned    bool
+	}{
+		{"loaded outside window", true, false, true, 60 * day, "", 0, false},
+		{"loaded outside window unmanaged", false, false, true, 60 * day, "", 0, false},
+		{"loaded window boundary", true, false, true, 31 * day, "", 0, false},
+		{"loaded inside window", true, false, true, 29 * day, "renewing certificate expiring", 1, false},
+		{"loaded expired", true, false, true, -day, "renewing certificate expiring", 1, false},
+		{"loaded inside window issued", false, true, true, 10 * day, "renewing certificate expiring", 1, false},
+		{"loaded inside window unmanaged", false, false, true, 10 * day, "", 1, true},
+		{"not loaded outside window", true, false, false, 60 * day, "", 1, false},
+		{"not loaded inside window", true, false, false, 10 * day, "renewing certificate expiring", 1, false},
+	}
+	for _, c := range cases {
+		certificatePem, keyPem := testCertificate(t, hosts, time.Now().Add(c.expiresIn))
+		tlsSecrets := &fakeTLSSecrets{certificates: map[string]*tls.Certificate{}}
+		if c.loaded {
+			certificate, err := tls.X509KeyPair(certificatePem, keyPem)
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			certificate.Leaf, _ = x509.ParseCertificate(certificate.Certificate[0])
+			tlsSecrets.certificates["default/tls"] = &certificate
+		}
+		m, store := newTestManager("", tlsSecrets)
+		secret := &v1.Secret{
+			ObjectMeta: meta_v1.ObjectMeta{Name: "tls", Namespace: "default"},
+			Data:       map[string][]byte{tlsCertificate: certificatePem, tlsPrivateKey: keyPem},
+		}
+		if c.annotated {
+			secret.Annotations = map[string]string{annotationIssued: "true"}
+		}
+		store.secrets["default/tls"] = secret
+
+		reason, err := m.requiresCertificate(&controller.TLSSecret{Namespace: "default", SecretName: "tls", Hosts: hosts, ACME: c.acme})
+		if err != nil {
+			t.Fatalf("unexpected error for %s %s", c.name, err)
+		}
+		if (c.expected == "" && reason != "") || !strings.HasPrefix(reason, c.expected) {
+			t.Fatalf("unexpected output for %s %s %s", c.name, reason, c.expected)
+		}
+		if store.gets != c.gets {
+			t.Fatalf("unexpected secret requests for %s %v %v", c.name, store.gets, c.gets)
+		}
+		if m.expiryWarned["default/tls"] != c.warned {
+			t.Fatalf("unexpected expiry warning for %s %v", c.name, m.expiryWarned)
+		}
+	}
+}
+
+func Test_Manager_reconcile_Backoff(t *testing.T) {
+	directory := newFakeDirectory()
+	defer directory.server.Close()
+	directory.rejectOrders = true
+
+	tlsSecrets := &fakeTLSSecrets{secrets: []*controller.TLSSecret{
+		{Namespace: "default", SecretName: "tls", Hosts: []string{"a.example.com"}, ACME: true},
+	}}
+	m, store := newTestManager(directory.server.URL+"/directory", tlsSecrets)
+	directory.manager = m
+
+	m.reconcile(context.Background())
+	if directory.orders != 1 {
+		t.Fatalf("unexpected orders %v", directory.orders)
+	}
+	if m.attempts["default/tls"] == nil || m.attempts["default/tls"].failures != 1 {
+		t.Fatalf("failure not recorded %v", m.attempts)
+	}
+
+	// Secret isn't checked or ordered again until the backoff passes
+	gets := store.gets
+	m.reconcile(context.Background())
+	if directory.orders != 1 || store.gets != gets {
+		t.Fatalf("unexpected attempt during backoff %v %v", directory.orders, store.gets)
+	}
+
+	m.attempts["default/tls"].next = time.Now().Add(-time.Second)
+	m.reconcile(context.Background())
+	if directory.orders != 2 {
+		t.Fatalf("unexpected orders after backoff %v", directory.orders)
+	}
+	if m.attempts["default/tls"].failures != 2 || time.Until(m.attempts["default/tls"].next) <= retryIntervalMin {
+		t.Fatalf("unexpected backoff after second failure %v", m.attempts["default/tls"])
+	}
+
+	// Successful issuance clears the backoff
+	directory.rejectOrders = false
+	m.attempts["default/tls"].next = time.Now().Add(-time.Second)
+	m.reconcile(context.Background())
+	if _, ok := m.attempts["default/tls"]; ok {
+		t.Fatalf("backoff not cleared after issuance")
+	}
+	if _, ok := store.secrets["default/tls"]; !ok {
+		t.Fatalf("secret not created")
+	}
+}
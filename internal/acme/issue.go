@@ -0,0 +1,116 @@
+package acme
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"strings"
+
+	"github.com/elijahglover/inbound/internal/controller"
+	"github.com/elijahglover/inbound/internal/helpers"
+	"golang.org/x/crypto/acme"
+)
+
+// issue orders a certificate for secret hosts and stores the result in the secret
+func (m *Manager) issue(ctx context.Context, secret *controller.TLSSecret) error {
+	hosts := make([]string, 0, len(secret.Hosts))
+	for _, host := range secret.Hosts {
+		// HTTP-01 is unable to validate wildcard names
+		if strings.HasPrefix(host, "*.") {
+			m.logger.Warningf("Skipping wildcard host %s for %s/%s, HTTP-01 can't validate wildcards", host, secret.Namespace, secret.SecretName)
+			continue
+		}
+		hosts = append(hosts, host)
+	}
+	if len(hosts) == 0 {
+		return fmt.Errorf("No hosts to issue certificate for")
+	}
+
+	order, err := m.acmeClient.AuthorizeOrder(ctx, acme.DomainIDs(hosts...))
+	if err != nil {
+		return err
+	}
+
+	for _, authorizationURL := range order.AuthzURLs {
+		err = m.authorize(ctx, authorizationURL)
+		if err != nil {
+			return err
+		}
+	}
+
+	order, err = m.acmeClient.WaitOrder(ctx, order.URI)
+	if err != nil {
+		return err
+	}
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: hosts[0]},
+		DNSNames: hosts,
+	}, privateKey)
+	if err != nil {
+		return err
+	}
+
+	chain, _, err := m.acmeClient.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return err
+	}
+
+	certificatePem := make([]byte, 0)
+	for _, certRaw := range chain {
+		block, err := helpers.EncodePem("CERTIFICATE", certRaw)
+		if err != nil {
+			return err
+		}
+		certificatePem = append(certificatePem, block...)
+	}
+	privateKeyPem, err := helpers.EncodePem("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(privateKey))
+	if err != nil {
+		return err
+	}
+
+	return m.storeCertificate(secret, certificatePem, privateKeyPem)
+}
+
+// authorize completes a HTTP-01 challenge for a single authorization
+func (m *Manager) authorize(ctx context.Context, authorizationURL string) error {
+	authorization, err := m.acmeClient.GetAuthorization(ctx, authorizationURL)
+	if err != nil {
+		return err
+	}
+	if authorization.Status == acme.StatusValid {
+		return nil
+	}
+
+	var challenge *acme.Challenge
+	for _, c := range authorization.Challenges {
+		if c.Type == "http-01" {
+			challenge = c
+			break
+		}
+	}
+	if challenge == nil {
+		return fmt.Errorf("No http-01 challenge offered for %s", authorization.Identifier.Value)
+	}
+
+	keyAuthorization, err := m.acmeClient.HTTP01ChallengeResponse(challenge.Token)
+	if err != nil {
+		return err
+	}
+	m.setToken(challenge.Token, keyAuthorization)
+	defer m.deleteToken(challenge.Token)
+
+	_, err = m.acmeClient.Accept(ctx, challenge)
+	if err != nil {
+		return err
+	}
+	_, err = m.acmeClient.WaitAuthorization(ctx, authorization.URI)
+	return err
+}
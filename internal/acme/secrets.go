@@ -0,0 +1,137 @@
+package acme
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/elijahglover/inbound/internal/controller"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	tlsPrivateKey  = "tls.key"
+	tlsCertificate = "tls.crt"
+	// annotationIssued marks secrets written by inbound so they are renewed
+	annotationIssued = "inbound.ingress.kubernetes.io/acme-issued"
+)
+
+// requiresCertificate returns why a certificate should be issued, empty when not required
+func (m *Manager) requiresCertificate(secret *controller.TLSSecret) (string, error) {
+	key := secret.Namespace + "/" + secret.SecretName
+
+	// Certificates already loaded by the certificate watcher outside the renewal window need no API request
+	var leaf *x509.Certificate
+	if loaded := m.controller.GetSecretCertificate(secret.Namespace, secret.SecretName); loaded != nil && loaded.Leaf != nil {
+		leaf = loaded.Leaf
+	}
+	if leaf != nil && !m.renewalDue(leaf) {
+		delete(m.expiryWarned, key)
+		return coverageReason(secret, leaf), nil
+	}
+
+	existing, err := m.secrets(secret.Namespace).Get(secret.SecretName, meta_v1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return "secret missing", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if leaf == nil {
+		leaf = parseLeafCertificate(existing.Data)
+	}
+
+	if leaf != nil && m.renewalDue(leaf) {
+		// Only replace certificates opted in or previously issued by inbound
+		if !secret.ACME && existing.Annotations[annotationIssued] != "true" {
+			if !m.expiryWarned[key] {
+				m.logger.Warningf("Certificate %s expires %s and isn't managed by inbound", key, leaf.NotAfter.Format(time.RFC3339))
+				m.expiryWarned[key] = true
+			}
+			return "", nil
+		}
+		return fmt.Sprintf("renewing certificate expiring %s", leaf.NotAfter.Format(time.RFC3339)), nil
+	}
+	delete(m.expiryWarned, key)
+
+	if secret.ACME && leaf == nil {
+		return "secret has no certificate", nil
+	}
+	return coverageReason(secret, leaf), nil
+}
+
+// renewalDue checks if leaf expires within the renewal window
+func (m *Manager) renewalDue(leaf *x509.Certificate) bool {
+	return time.Until(leaf.NotAfter) < m.config.ACMERenewBefore
+}
+
+// coverageReason returns the first host of an ACME secret leaf doesn't cover, empty when all are covered
+func coverageReason(secret *controller.TLSSecret, leaf *x509.Certificate) string {
+	if !secret.ACME || leaf == nil {
+		return ""
+	}
+	for _, host := range secret.Hosts {
+		// Wildcards are never issued, see issue
+		if strings.HasPrefix(host, "*.") {
+			continue
+		}
+		if leaf.VerifyHostname(host) != nil {
+			return fmt.Sprintf("certificate doesn't cover %s", host)
+		}
+	}
+	return ""
+}
+
+// storeCertificate writes certificate into secret, the certificate watcher picks up the change
+func (m *Manager) storeCertificate(secret *controller.TLSSecret, certificatePem []byte, privateKeyPem []byte) error {
+	secrets := m.secrets(secret.Namespace)
+
+	existing, err := secrets.Get(secret.SecretName, meta_v1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err = secrets.Create(&v1.Secret{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:        secret.SecretName,
+				Namespace:   secret.Namespace,
+				Annotations: map[string]string{annotationIssued: "true"},
+			},
+			Type: v1.SecretTypeTLS,
+			Data: map[string][]byte{
+				tlsCertificate: certificatePem,
+				tlsPrivateKey:  privateKeyPem,
+			},
+		})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if existing.Data == nil {
+		existing.Data = map[string][]byte{}
+	}
+	if existing.Annotations == nil {
+		existing.Annotations = map[string]string{}
+	}
+	existing.Annotations[annotationIssued] = "true"
+	existing.Data[tlsCertificate] = certificatePem
+	existing.Data[tlsPrivateKey] = privateKeyPem
+	_, err = secrets.Update(existing)
+	return err
+}
+
+// parseLeafCertificate returns the first certificate in secret or nil
+func parseLeafCertificate(data map[string][]byte) *x509.Certificate {
+	block, _ := pem.Decode(data[tlsCertificate])
+	if block == nil {
+		return nil
+	}
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil
+	}
+	return certificate
+}
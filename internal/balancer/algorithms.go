@@ -0,0 +1,85 @@
+package balancer
+
+import (
+	"math/rand"
+	"sync"
+)
+
+type roundRobin struct {
+	counters     map[string]uint64
+	countersLock *sync.Mutex
+}
+
+func newRoundRobin() *roundRobin {
+	return &roundRobin{
+		counters:     map[string]uint64{},
+		countersLock: &sync.Mutex{},
+	}
+}
+
+func (b *roundRobin) Next(key string, targets []string) string {
+	if len(targets) == 0 {
+		return ""
+	}
+
+	b.countersLock.Lock()
+	counter := b.counters[key]
+	b.counters[key] = counter + 1
+	b.countersLock.Unlock()
+
+	return targets[counter%uint64(len(targets))]
+}
+
+type leastConnections struct {
+	connections *Connections
+}
+
+func newLeastConnections(connections *Connections) *leastConnections {
+	return &leastConnections{connections: connections}
+}
+
+func (b *leastConnections) Next(key string, targets []string) string {
+	if len(targets) == 0 {
+		return ""
+	}
+
+	selected := targets[0]
+	selectedCount := b.connections.Count(selected)
+	for _, target := range targets[1:] {
+		count := b.connections.Count(target)
+		if count < selectedCount {
+			selected = target
+			selectedCount = count
+		}
+	}
+	return selected
+}
+
+type randomTwoChoices struct {
+	connections *Connections
+}
+
+func newRandomTwoChoices(connections *Connections) *randomTwoChoices {
+	return &randomTwoChoices{connections: connections}
+}
+
+func (b *randomTwoChoices) Next(key string, targets []string) string {
+	if len(targets) == 0 {
+		return ""
+	}
+	if len(targets) == 1 {
+		return targets[0]
+	}
+
+	// Pick two distinct targets and use whichever has less load
+	first := rand.Intn(len(targets))
+	second := rand.Intn(len(targets) - 1)
+	if second >= first {
+		second++
+	}
+
+	if b.connections.Count(targets[second]) < b.connections.Count(targets[first]) {
+		return targets[second]
+	}
+	return targets[first]
+}
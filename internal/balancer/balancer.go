@@ -0,0 +1,28 @@
+package balancer
+
+const (
+	// RoundRobin cycles through targets in order
+	RoundRobin = "round-robin"
+	// LeastConnections picks the target with the fewest active requests
+	LeastConnections = "least-connections"
+	// RandomTwoChoices picks two random targets and uses the least loaded
+	RandomTwoChoices = "random-two-choices"
+)
+
+// Balancer selects a single target from a set of upstream targets
+type Balancer interface {
+	// Next target for key, targets are expected in stable order
+	Next(key string, targets []string) string
+}
+
+// New balancer for algorithm, unknown algorithms fall back to round robin
+func New(algorithm string, connections *Connections) Balancer {
+	switch algorithm {
+	case LeastConnections:
+		return newLeastConnections(connections)
+	case RandomTwoChoices:
+		return newRandomTwoChoices(connections)
+	default:
+		return newRoundRobin()
+	}
+}
@@ -0,0 +1,57 @@
+package balancer_test
+
+import (
+	"testing"
+
+	"github.com/elijahglover/inbound/internal/balancer"
+)
+
+func Test_RoundRobin_Next(t *testing.T) {
+	targets := []string{"10.0.0.1:80", "10.0.0.2:80", "10.0.0.3:80"}
+	b := balancer.New(balancer.RoundRobin, balancer.NewConnections())
+
+	for i := 0; i < 6; i++ {
+		expected := targets[i%len(targets)]
+		actual := b.Next("default/web", targets)
+		if actual != expected {
+			t.Fatalf("unexpected output %s %s", actual, expected)
+		}
+	}
+}
+
+func Test_LeastConnections_Next(t *testing.T) {
+	targets := []string{"10.0.0.1:80", "10.0.0.2:80"}
+	connections := balancer.NewConnections()
+	b := balancer.New(balancer.LeastConnections, connections)
+
+	connections.Acquire("10.0.0.1:80")
+	expected := "10.0.0.2:80"
+	actual := b.Next("default/web", targets)
+	if actual != expected {
+		t.Fatalf("unexpected output %s %s", actual, expected)
+	}
+
+	connections.Release("10.0.0.1:80")
+	connections.Acquire("10.0.0.2:80")
+	expected = "10.0.0.1:80"
+	actual = b.Next("default/web", targets)
+	if actual != expected {
+		t.Fatalf("unexpected output %s %s", actual, expected)
+	}
+}
+
+func Test_RandomTwoChoices_Next(t *testing.T) {
+	targets := []string{"10.0.0.1:80", "10.0.0.2:80"}
+	connections := balancer.NewConnections()
+	b := balancer.New(balancer.RandomTwoChoices, connections)
+
+	// With two targets both are always compared so the idle one must win
+	connections.Acquire("10.0.0.2:80")
+	for i := 0; i < 10; i++ {
+		expected := "10.0.0.1:80"
+		actual := b.Next("default/web", targets)
+		if actual != expected {
+			t.Fatalf("unexpected output %s %s", actual, expected)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package balancer
+
+import "sync"
+
+// Connections tracks active requests for each target
+type Connections struct {
+	active     map[string]int64
+	activeLock *sync.Mutex
+}
+
+// NewConnections tracker
+func NewConnections() *Connections {
+	return &Connections{
+		active:     map[string]int64{},
+		activeLock: &sync.Mutex{},
+	}
+}
+
+// Acquire marks a request as active against target
+func (c *Connections) Acquire(target string) {
+	c.activeLock.Lock()
+	defer c.activeLock.Unlock()
+	c.active[target]++
+}
+
+// Release marks a request as completed against target
+func (c *Connections) Release(target string) {
+	c.activeLock.Lock()
+	defer c.activeLock.Unlock()
+
+	c.active[target]--
+	if c.active[target] <= 0 {
+		delete(c.active, target)
+	}
+}
+
+// Count of active requests against target
+func (c *Connections) Count(target string) int64 {
+	c.activeLock.Lock()
+	defer c.activeLock.Unlock()
+	return c.active[target]
+}
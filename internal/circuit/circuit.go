@@ -0,0 +1,167 @@
+package circuit
+
+import (
+	"sync"
+	"time"
+
+	"github.com/elijahglover/inbound/internal/logger"
+)
+
+// Settings controls when a circuit opens and how long it stays open
+type Settings struct {
+	Threshold int
+	Cooldown  time.Duration
+}
+
+// Status represents the state of a single circuit
+type Status struct {
+	Key       string
+	Open      bool
+	Failures  int
+	OpenUntil time.Time
+}
+
+type circuit struct {
+	failures      int
+	open          bool
+	openUntil     time.Time
+	trialInFlight bool
+}
+
+// Breaker tracks consecutive failures for upstream targets
+type Breaker struct {
+	logger       logger.Logger
+	circuits     map[string]*circuit
+	circuitsLock *sync.Mutex
+}
+
+// New circuit breaker
+func New(logger logger.Logger) *Breaker {
+	return &Breaker{
+		logger:       logger,
+		circuits:     map[string]*circuit{},
+		circuitsLock: &sync.Mutex{},
+	}
+}
+
+// Available returns true if requests may be sent to key, the trial request is claimed by Acquire
+// Once cool down has elapsed a single trial request is allowed (half open)
+func (b *Breaker) Available(key string) bool {
+	b.circuitsLock.Lock()
+	defer b.circuitsLock.Unlock()
+
+	c, ok := b.circuits[key]
+	if !ok || !c.open {
+		return true
+	}
+	if time.Now().Before(c.openUntil) {
+		return false
+	}
+	return !c.trialInFlight
+}
+
+// RetryAfter returns the shortest remaining open duration across keys
+func (b *Breaker) RetryAfter(keys []string) time.Duration {
+	b.circuitsLock.Lock()
+	defer b.circuitsLock.Unlock()
+
+	var retryAfter time.Duration
+	for _, key := range keys {
+		c, ok := b.circuits[key]
+		if !ok || !c.open {
+			continue
+		}
+		remaining := time.Until(c.openUntil)
+		if remaining < time.Second {
+			remaining = time.Second
+		}
+		if retryAfter == 0 || remaining < retryAfter {
+			retryAfter = remaining
+		}
+	}
+	return retryAfter
+}
+
+// Acquire claims key for a request, returns false while the circuit is open
+// Once cool down has elapsed the first caller is given the single trial request (half open)
+func (b *Breaker) Acquire(key string) (acquired bool, trial bool) {
+	b.circuitsLock.Lock()
+	defer b.circuitsLock.Unlock()
+
+	c, ok := b.circuits[key]
+	if !ok || !c.open {
+		return true, false
+	}
+	if time.Now().Before(c.openUntil) || c.trialInFlight {
+		return false, false
+	}
+	c.trialInFlight = true
+	return true, true
+}
+
+// Success resets failures for key, an open circuit is only closed by its trial request
+func (b *Breaker) Success(key string, trial bool) {
+	b.circuitsLock.Lock()
+	defer b.circuitsLock.Unlock()
+
+	c, ok := b.circuits[key]
+	if !ok {
+		return
+	}
+	if c.open {
+		// Requests sent before the circuit opened say nothing about recovery
+		if !trial {
+			return
+		}
+		b.logger.Infof("Circuit closed for upstream %s", key)
+	}
+	delete(b.circuits, key)
+}
+
+// Failure records a failed request and opens the circuit once threshold is reached
+func (b *Breaker) Failure(key string, settings Settings, trial bool) {
+	b.circuitsLock.Lock()
+	defer b.circuitsLock.Unlock()
+
+	c, ok := b.circuits[key]
+	if !ok {
+		c = &circuit{}
+		b.circuits[key] = c
+	}
+	c.failures++
+
+	if c.open {
+		// Requests sent before the circuit opened don't affect the trial
+		if !trial {
+			return
+		}
+		// Failed trial request re-opens immediately
+		c.trialInFlight = false
+		c.openUntil = time.Now().Add(settings.Cooldown)
+		b.logger.Warningf("Circuit re-opened for upstream %s for %s", key, settings.Cooldown)
+		return
+	}
+
+	if c.failures >= settings.Threshold {
+		c.open = true
+		c.openUntil = time.Now().Add(settings.Cooldown)
+		b.logger.Warningf("Circuit opened for upstream %s after %v failures for %s", key, c.failures, settings.Cooldown)
+	}
+}
+
+// Statuses returns a snapshot of all tracked circuits
+func (b *Breaker) Statuses() []Status {
+	b.circuitsLock.Lock()
+	defer b.circuitsLock.Unlock()
+
+	statuses := make([]Status, 0, len(b.circuits))
+	for key, c := range b.circuits {
+		statuses = append(statuses, Status{
+			Key:       key,
+			Open:      c.open,
+			Failures:  c.failures,
+			OpenUntil: c.openUntil,
+		})
+	}
+	return statuses
+}
@@ -0,0 +1,129 @@
+package circuit_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elijahglover/inbound/internal/circuit"
+	"github.com/elijahglover/inbound/internal/logger"
+)
+
+func Test_Circuit_Opens_After_Threshold(t *testing.T) {
+	breaker := circuit.New(logger.NewNull())
+	settings := circuit.Settings{Threshold: 2, Cooldown: time.Minute}
+	key := "10.0.0.1:80"
+
+	breaker.Failure(key, settings, false)
+	if !breaker.Available(key) {
+		t.Fatalf("circuit opened before threshold")
+	}
+
+	breaker.Failure(key, settings, false)
+	if breaker.Available(key) {
+		t.Fatalf("circuit not opened after threshold")
+	}
+
+	if breaker.RetryAfter([]string{key}) <= 0 {
+		t.Fatalf("expected retry after for open circuit")
+	}
+}
+
+func Test_Circuit_Half_Open_Trial(t *testing.T) {
+	breaker := circuit.New(logger.NewNull())
+	settings := circuit.Settings{Threshold: 1, Cooldown: time.Millisecond}
+	key := "10.0.0.1:80"
+
+	breaker.Failure(key, settings, false)
+	time.Sleep(5 * time.Millisecond)
+
+	if !breaker.Available(key) {
+		t.Fatalf("circuit not half open after cool down")
+	}
+
+	// Only a single trial request is allowed through
+	if acquired, trial := breaker.Acquire(key); !acquired || !trial {
+		t.Fatalf("trial request not acquired %v %v", acquired, trial)
+	}
+	if breaker.Available(key) {
+		t.Fatalf("second trial request allowed while half open")
+	}
+
+	breaker.Success(key, true)
+	if !breaker.Available(key) {
+		t.Fatalf("circuit not closed after successful trial")
+	}
+}
+
+func Test_Circuit_Half_Open_Single_Trial_Concurrent(t *testing.T) {
+	breaker := circuit.New(logger.NewNull())
+	settings := circuit.Settings{Threshold: 1, Cooldown: time.Millisecond}
+	key := "10.0.0.1:80"
+
+	breaker.Failure(key, settings, false)
+	time.Sleep(5 * time.Millisecond)
+
+	trials := make(chan bool, 50)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if acquired, trial := breaker.Acquire(key); acquired {
+				trials <- trial
+			}
+		}()
+	}
+	wg.Wait()
+	close(trials)
+
+	acquired := 0
+	for trial := range trials {
+		if !trial {
+			t.Fatalf("request acquired without being the trial")
+		}
+		acquired++
+	}
+	if acquired != 1 {
+		t.Fatalf("unexpected trial requests %v", acquired)
+	}
+}
+
+func Test_Circuit_Stays_Open_On_Stale_Success(t *testing.T) {
+	breaker := circuit.New(logger.NewNull())
+	settings := circuit.Settings{Threshold: 1, Cooldown: time.Minute}
+	key := "10.0.0.1:80"
+
+	// Slow requests sent before the circuit opened
+	started := make(chan struct{})
+	opened := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			breaker.Acquire(key)
+			started <- struct{}{}
+			<-opened
+			breaker.Success(key, false)
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		<-started
+	}
+
+	breaker.Failure(key, settings, false)
+	close(opened)
+	wg.Wait()
+
+	if breaker.Available(key) {
+		t.Fatalf("circuit closed by request sent before it opened")
+	}
+
+	// Failures from stale requests don't extend the cool down either
+	retryAfter := breaker.RetryAfter([]string{key})
+	breaker.Failure(key, circuit.Settings{Threshold: 1, Cooldown: time.Hour}, false)
+	if breaker.RetryAfter([]string{key}) > retryAfter {
+		t.Fatalf("cool down extended by request sent before circuit opened")
+	}
+}
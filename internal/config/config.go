@@ -1,7 +1,14 @@
 package config
 
 import (
+	"fmt"
+	"net"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elijahglover/inbound/internal/helpers"
 )
 
 // Config represents application configuration
@@ -20,6 +27,72 @@ type Config struct {
 	LogInfo bool
 	// LogWarning log warning
 	LogWarning bool
+	// RetryBudgetPercent caps active retries as a percentage of active requests
+	RetryBudgetPercent int
+	// ReadHeaderTimeout client timeout reading request headers
+	ReadHeaderTimeout time.Duration
+	// ReadTimeout client timeout reading the entire request, zero allows slow uploads
+	ReadTimeout time.Duration
+	// WriteTimeout client timeout writing the response, zero allows streaming
+	WriteTimeout time.Duration
+	// IdleTimeout client keep-alive timeout between requests
+	IdleTimeout time.Duration
+	// UpstreamConnectTimeout timeout dialing upstream
+	UpstreamConnectTimeout time.Duration
+	// UpstreamResponseTimeout timeout waiting for upstream response headers
+	UpstreamResponseTimeout time.Duration
+	// UpstreamIdleTimeout timeout for idle upstream keep-alive connections
+	UpstreamIdleTimeout time.Duration
+	// RequestTimeout overall request timeout, zero disables
+	RequestTimeout time.Duration
+	// HTTP2 negotiates HTTP/2 via ALPN on the HTTPS listener
+	HTTP2 bool
+	// ACMEEnabled issues certificates for ingress TLS hosts
+	ACMEEnabled bool
+	// ACMEDirectoryURL ACME server directory
+	ACMEDirectoryURL string
+	// ACMEEmail contact address registered with the ACME account
+	ACMEEmail string
+	// ACMEAccountSecret namespace/name of secret persisting the account key, empty uses a new key each start
+	ACMEAccountSecret string
+	// ACMEInsecureSkipVerify skips TLS verification of the ACME server, for local test servers only
+	ACMEInsecureSkipVerify bool
+	// ACMERenewBefore renews managed certificates this long before expiry
+	ACMERenewBefore time.Duration
+	// AccessLogFormat combined, json, logfmt or off
+	AccessLogFormat string
+	// AccessLogSampleRate fraction of requests logged between 0 and 1
+	AccessLogSampleRate float64
+	// DefaultBackendService namespace/name of service receiving unmatched traffic, empty disables
+	DefaultBackendService string
+	// DefaultBackendPort port of default backend service
+	DefaultBackendPort int
+	// SSLRedirect redirects plain HTTP requests to HTTPS
+	SSLRedirect bool
+	// SSLRedirectStatus 301, 302, 307 or 308
+	SSLRedirectStatus int
+	// SSLRedirectPort HTTPS port used in redirect location, 443 is omitted
+	SSLRedirectPort string
+	// HSTSMaxAge seconds, zero disables Strict-Transport-Security
+	HSTSMaxAge int
+	// HSTSIncludeSubDomains applies HSTS to subdomains
+	HSTSIncludeSubDomains bool
+	// HSTSPreload requests inclusion in browser preload lists
+	HSTSPreload bool
+	// ErrorPagesConfigMap namespace/name of configmap holding error page templates, empty disables
+	ErrorPagesConfigMap string
+	// InterceptErrors replaces upstream 5xx responses with error pages
+	InterceptErrors bool
+	// Compression compresses responses negotiated from Accept-Encoding
+	Compression bool
+	// CompressionMinSize bytes, responses with a smaller Content-Length are sent uncompressed
+	CompressionMinSize int
+	// CompressionTypes MIME types compressed
+	CompressionTypes []string
+	// AffinitySecret signs session affinity cookies, empty uses a random key per process
+	AffinitySecret string
+	// TrustedProxies load balancers in front of inbound whose X-Forwarded-For is trusted
+	TrustedProxies []*net.IPNet
 }
 
 // FromEnv loads config from environment variables
@@ -30,6 +103,35 @@ func FromEnv() (*Config, error) {
 		LogVerbose: false,
 		LogInfo:    true,
 		LogWarning: true,
+
+		RetryBudgetPercent: 20,
+
+		ReadHeaderTimeout:       10 * time.Second,
+		IdleTimeout:             120 * time.Second,
+		UpstreamConnectTimeout:  5 * time.Second,
+		UpstreamResponseTimeout: 60 * time.Second,
+		UpstreamIdleTimeout:     90 * time.Second,
+
+		HTTP2: true,
+
+		ACMEDirectoryURL: "https://acme-v02.api.letsencrypt.org/directory",
+		ACMERenewBefore:  30 * 24 * time.Hour,
+
+		AccessLogFormat:     "combined",
+		AccessLogSampleRate: 1,
+
+		DefaultBackendPort: 80,
+
+		CompressionMinSize: 1024,
+		CompressionTypes: []string{
+			"text/html", "text/css", "text/plain", "text/xml", "text/javascript",
+			"application/javascript", "application/json", "application/xml", "image/svg+xml",
+		},
+
+		SSLRedirect:           true,
+		SSLRedirectStatus:     301,
+		HSTSMaxAge:            63072000,
+		HSTSIncludeSubDomains: true,
 	}
 
 	conf.TargetNamespace = os.Getenv("TARGET_NAMESPACE")
@@ -44,5 +146,158 @@ func FromEnv() (*Config, error) {
 		conf.LogInfo = false
 	}
 
+	if os.Getenv("HTTP2_ENABLED") == "false" {
+		conf.HTTP2 = false
+	}
+
+	if os.Getenv("ACME_ENABLED") == "true" {
+		conf.ACMEEnabled = true
+	}
+	if os.Getenv("ACME_DIRECTORY_URL") != "" {
+		conf.ACMEDirectoryURL = os.Getenv("ACME_DIRECTORY_URL")
+	}
+	conf.ACMEEmail = os.Getenv("ACME_EMAIL")
+	conf.ACMEAccountSecret = os.Getenv("ACME_ACCOUNT_SECRET")
+	if os.Getenv("ACME_INSECURE_SKIP_VERIFY") == "true" {
+		conf.ACMEInsecureSkipVerify = true
+	}
+
+	if os.Getenv("ACCESS_LOG_FORMAT") != "" {
+		conf.AccessLogFormat = os.Getenv("ACCESS_LOG_FORMAT")
+	}
+	if os.Getenv("ACCESS_LOG_SAMPLE_RATE") != "" {
+		value, err := strconv.ParseFloat(os.Getenv("ACCESS_LOG_SAMPLE_RATE"), 64)
+		if err != nil || value < 0 || value > 1 {
+			return nil, fmt.Errorf("Invalid ACCESS_LOG_SAMPLE_RATE %s", os.Getenv("ACCESS_LOG_SAMPLE_RATE"))
+		}
+		conf.AccessLogSampleRate = value
+	}
+
+	if value := os.Getenv("DEFAULT_BACKEND_SERVICE"); value != "" {
+		if parts := strings.Split(value, "/"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("Invalid DEFAULT_BACKEND_SERVICE %s, expected namespace/name", value)
+		}
+		conf.DefaultBackendService = value
+	}
+	if err := intFromEnv("DEFAULT_BACKEND_PORT", &conf.DefaultBackendPort); err != nil {
+		return nil, err
+	}
+
+	if os.Getenv("SSL_REDIRECT") == "false" {
+		conf.SSLRedirect = false
+	}
+	if err := intFromEnv("SSL_REDIRECT_STATUS", &conf.SSLRedirectStatus); err != nil {
+		return nil, err
+	}
+	if !validRedirectStatus(conf.SSLRedirectStatus) {
+		return nil, fmt.Errorf("Invalid SSL_REDIRECT_STATUS %v", conf.SSLRedirectStatus)
+	}
+	conf.SSLRedirectPort = conf.HTTPSPort
+	if os.Getenv("SSL_REDIRECT_PORT") != "" {
+		conf.SSLRedirectPort = os.Getenv("SSL_REDIRECT_PORT")
+	}
+	if err := intFromEnv("HSTS_MAX_AGE", &conf.HSTSMaxAge); err != nil {
+		return nil, err
+	}
+	if os.Getenv("HSTS_INCLUDE_SUBDOMAINS") == "false" {
+		conf.HSTSIncludeSubDomains = false
+	}
+	if os.Getenv("HSTS_PRELOAD") == "true" {
+		conf.HSTSPreload = true
+	}
+
+	if value := os.Getenv("ERROR_PAGES_CONFIGMAP"); value != "" {
+		if parts := strings.Split(value, "/"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("Invalid ERROR_PAGES_CONFIGMAP %s, expected namespace/name", value)
+		}
+		conf.ErrorPagesConfigMap = value
+	}
+	if os.Getenv("INTERCEPT_ERRORS") == "true" {
+		conf.InterceptErrors = true
+	}
+
+	if os.Getenv("COMPRESSION_ENABLED") == "true" {
+		conf.Compression = true
+	}
+	if err := intFromEnv("COMPRESSION_MIN_SIZE", &conf.CompressionMinSize); err != nil {
+		return nil, err
+	}
+	if value := os.Getenv("COMPRESSION_TYPES"); value != "" {
+		conf.CompressionTypes = []string{}
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				conf.CompressionTypes = append(conf.CompressionTypes, item)
+			}
+		}
+	}
+
+	conf.AffinitySecret = os.Getenv("AFFINITY_SECRET")
+
+	if os.Getenv("TRUSTED_PROXIES") != "" {
+		networks, err := helpers.ParseCIDRs(os.Getenv("TRUSTED_PROXIES"))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid TRUSTED_PROXIES %s", err)
+		}
+		conf.TrustedProxies = networks
+	}
+
+	if err := intFromEnv("RETRY_BUDGET_PERCENT", &conf.RetryBudgetPercent); err != nil {
+		return nil, err
+	}
+
+	durations := map[string]*time.Duration{
+		"READ_HEADER_TIMEOUT":       &conf.ReadHeaderTimeout,
+		"READ_TIMEOUT":              &conf.ReadTimeout,
+		"WRITE_TIMEOUT":             &conf.WriteTimeout,
+		"IDLE_TIMEOUT":              &conf.IdleTimeout,
+		"UPSTREAM_CONNECT_TIMEOUT":  &conf.UpstreamConnectTimeout,
+		"UPSTREAM_RESPONSE_TIMEOUT": &conf.UpstreamResponseTimeout,
+		"UPSTREAM_IDLE_TIMEOUT":     &conf.UpstreamIdleTimeout,
+		"REQUEST_TIMEOUT":           &conf.RequestTimeout,
+		"ACME_RENEW_BEFORE":         &conf.ACMERenewBefore,
+	}
+	for name, value := range durations {
+		if err := durationFromEnv(name, value); err != nil {
+			return nil, err
+		}
+	}
+
 	return conf, nil
 }
+
+// validRedirectStatus checks status is a redirect
+func validRedirectStatus(status int) bool {
+	switch status {
+	case 301, 302, 307, 308:
+		return true
+	}
+	return false
+}
+
+// intFromEnv overrides value when environment variable is set
+func intFromEnv(name string, value *int) error {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return nil
+	}
+	parsed, err := strconv.Atoi(raw)
+	if err != nil || parsed < 0 {
+		return fmt.Errorf("Invalid %s %s", name, raw)
+	}
+	*value = parsed
+	return nil
+}
+
+// durationFromEnv overrides value when environment variable is set
+func durationFromEnv(name string, value *time.Duration) error {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return nil
+	}
+	parsed, err := time.ParseDuration(raw)
+	if err != nil || parsed < 0 {
+		return fmt.Errorf("Invalid %s %s", name, raw)
+	}
+	*value = parsed
+	return nil
+}
@@ -0,0 +1,532 @@
+package controller
+
+import (
+	"math"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elijahglover/inbound/internal/balancer"
+	"github.com/elijahglover/inbound/internal/helpers"
+	"k8s.io/api/extensions/v1beta1"
+)
+
+const annotationPrefix = "inbound.ingress.kubernetes.io/"
+
+const (
+	// BackendProtocolHTTP proxies to upstream using HTTP/1.1
+	BackendProtocolHTTP = "HTTP"
+	// BackendProtocolH2C proxies to upstream using cleartext HTTP/2
+	BackendProtocolH2C = "H2C"
+	// BackendProtocolGRPC proxies to upstream using cleartext HTTP/2 preserving gRPC trailers
+	BackendProtocolGRPC = "GRPC"
+)
+
+const (
+	// MatchExact matches the request path exactly
+	MatchExact = "Exact"
+	// MatchPrefix matches the request path by path segments
+	MatchPrefix = "Prefix"
+	// MatchRegex matches the request path with a regular expression anchored at the start
+	MatchRegex = "Regex"
+	// matchImplementationSpecific is treated as prefix
+	matchImplementationSpecific = "ImplementationSpecific"
+)
+
+const (
+	// RateLimitKeyIP limits each client ip separately
+	RateLimitKeyIP = "ip"
+	// RateLimitKeyHeader limits each value of a request header separately
+	RateLimitKeyHeader = "header"
+	// RateLimitKeyRoute limits all requests to the route together
+	RateLimitKeyRoute = "route"
+)
+
+const (
+	// annotationAccessLog disables access logging when false
+	annotationAccessLog = annotationPrefix + "access-log"
+	// annotationACME opts in to certificate issuance for tls hosts
+	annotationACME = annotationPrefix + "acme"
+	// annotationBackendProtocol selects the protocol used to talk to upstream
+	annotationBackendProtocol = annotationPrefix + "backend-protocol"
+	// annotationLoadBalance selects the balancing algorithm for upstream endpoints
+	annotationLoadBalance = annotationPrefix + "load-balance"
+	// annotationHealthCheckPath enables active health checks against path
+	annotationHealthCheckPath               = annotationPrefix + "health-check-path"
+	annotationHealthCheckInterval           = annotationPrefix + "health-check-interval"
+	annotationHealthCheckTimeout            = annotationPrefix + "health-check-timeout"
+	annotationHealthCheckUnhealthyThreshold = annotationPrefix + "health-check-unhealthy-threshold"
+	annotationHealthCheckHealthyThreshold   = annotationPrefix + "health-check-healthy-threshold"
+	// annotationCircuitBreakerThreshold enables circuit breaking after consecutive failures
+	annotationCircuitBreakerThreshold = annotationPrefix + "circuit-breaker-threshold"
+	annotationCircuitBreakerCooldown  = annotationPrefix + "circuit-breaker-cooldown"
+	// annotationRetryAttempts enables retries of idempotent requests
+	annotationRetryAttempts      = annotationPrefix + "retry-attempts"
+	annotationRetryPerTryTimeout = annotationPrefix + "retry-per-try-timeout"
+	annotationRetryOn            = annotationPrefix + "retry-on"
+	// Timeout overrides for upstream connections and the overall request
+	annotationConnectTimeout  = annotationPrefix + "connect-timeout"
+	annotationResponseTimeout = annotationPrefix + "response-timeout"
+	annotationIdleTimeout     = annotationPrefix + "idle-timeout"
+	annotationRequestTimeout  = annotationPrefix + "request-timeout"
+	// annotationRateLimitRPS enables rate limiting, key is ip, route or header:<name>
+	annotationRateLimitRPS   = annotationPrefix + "rate-limit-rps"
+	annotationRateLimitBurst = annotationPrefix + "rate-limit-burst"
+	annotationRateLimitKey   = annotationPrefix + "rate-limit-key"
+	// annotationAllowSourceRange comma separated CIDR ranges allowed to reach the ingress
+	annotationAllowSourceRange = annotationPrefix + "allow-source-range"
+	annotationDenySourceRange  = annotationPrefix + "deny-source-range"
+	// annotationPathAllowSourceRange overrides ranges per path, formatted as /path=range,range;/other=range
+	annotationPathAllowSourceRange = annotationPrefix + "path-allow-source-range"
+	annotationPathDenySourceRange  = annotationPrefix + "path-deny-source-range"
+	// annotationAuthSecret names a secret in the ingress namespace holding htpasswd credentials under auth
+	annotationAuthSecret = annotationPrefix + "auth-secret"
+	annotationAuthRealm  = annotationPrefix + "auth-realm"
+	// annotationAuthURL enables forward auth, a 2xx response from the url allows the request
+	annotationAuthURL             = annotationPrefix + "auth-url"
+	annotationAuthRequestHeaders  = annotationPrefix + "auth-request-headers"
+	annotationAuthResponseHeaders = annotationPrefix + "auth-response-headers"
+	annotationAuthTimeout         = annotationPrefix + "auth-timeout"
+	// annotationRewriteStripPrefix removes the matched path before proxying
+	annotationRewriteStripPrefix = annotationPrefix + "rewrite-strip-prefix"
+	// annotationRewritePrefix replaces the matched path before proxying
+	annotationRewritePrefix = annotationPrefix + "rewrite-prefix"
+	// annotationRewriteRegex rewrites matching paths to rewrite-target, eg /$1
+	annotationRewriteRegex  = annotationPrefix + "rewrite-regex"
+	annotationRewriteTarget = annotationPrefix + "rewrite-target"
+	// annotationMatchType is Exact, Prefix or Regex, path-match-type overrides it per path as /path=Exact;/other=Regex
+	annotationMatchType     = annotationPrefix + "match-type"
+	annotationPathMatchType = annotationPrefix + "path-match-type"
+	// annotationSSLRedirect disables redirecting HTTP to HTTPS when false
+	annotationSSLRedirect       = annotationPrefix + "ssl-redirect"
+	annotationSSLRedirectStatus = annotationPrefix + "ssl-redirect-status"
+	annotationSSLRedirectPort   = annotationPrefix + "ssl-redirect-port"
+	// annotationHSTSMaxAge in seconds, 0 disables Strict-Transport-Security
+	annotationHSTSMaxAge            = annotationPrefix + "hsts-max-age"
+	annotationHSTSIncludeSubDomains = annotationPrefix + "hsts-include-subdomains"
+	annotationHSTSPreload           = annotationPrefix + "hsts-preload"
+	// annotationCanary marks an ingress as canary for paths of another ingress
+	annotationCanary            = annotationPrefix + "canary"
+	annotationCanaryWeight      = annotationPrefix + "canary-weight"
+	annotationCanaryHeader      = annotationPrefix + "canary-by-header"
+	annotationCanaryHeaderValue = annotationPrefix + "canary-by-header-value"
+	annotationCanaryCookie      = annotationPrefix + "canary-by-cookie"
+	// annotationAffinity enables session affinity when cookie
+	annotationAffinity           = annotationPrefix + "affinity"
+	annotationAffinityCookieName = annotationPrefix + "affinity-cookie-name"
+	annotationAffinityCookieTTL  = annotationPrefix + "affinity-cookie-ttl"
+	annotationAffinityCookiePath = annotationPrefix + "affinity-cookie-path"
+	// annotationEnableCORS answers preflight requests and adds CORS headers when true
+	annotationEnableCORS           = annotationPrefix + "enable-cors"
+	annotationCORSAllowOrigin      = annotationPrefix + "cors-allow-origin"
+	annotationCORSAllowMethods     = annotationPrefix + "cors-allow-methods"
+	annotationCORSAllowHeaders     = annotationPrefix + "cors-allow-headers"
+	annotationCORSExposeHeaders    = annotationPrefix + "cors-expose-headers"
+	annotationCORSAllowCredentials = annotationPrefix + "cors-allow-credentials"
+	annotationCORSMaxAge           = annotationPrefix + "cors-max-age"
+	// annotationCompression enables or disables response compression overriding the global setting
+	annotationCompression = annotationPrefix + "compression"
+	// annotationInterceptErrors replaces upstream 5xx responses with error pages when true
+	annotationInterceptErrors = annotationPrefix + "intercept-errors"
+)
+
+// defaultIngressOptions used for ingresses without annotations
+func defaultIngressOptions() *IngressOptions {
+	return &IngressOptions{
+		AccessLog:       true,
+		BackendProtocol: BackendProtocolHTTP,
+		LoadBalance:     balancer.RoundRobin,
+		MatchType:       MatchPrefix,
+	}
+}
+
+func (c *Controller) parseIngressOptions(ingress *v1beta1.Ingress) *IngressOptions {
+	ingressKey := namespaceFormat(ingress.Namespace, ingress.Name)
+	annotations := ingress.Annotations
+
+	options := defaultIngressOptions()
+	options.AccessLog = annotations[annotationAccessLog] != "false"
+
+	if value, ok := annotations[annotationACME]; ok {
+		options.ACME = value == "true"
+	}
+
+	if annotations[annotationCanary] == "true" {
+		options.Canary = &CanaryOptions{
+			Header:      annotations[annotationCanaryHeader],
+			HeaderValue: annotations[annotationCanaryHeaderValue],
+			Cookie:      annotations[annotationCanaryCookie],
+		}
+		if value, ok := annotations[annotationCanaryWeight]; ok {
+			weight, err := strconv.Atoi(value)
+			if err != nil || weight < 0 || weight > 100 {
+				c.logger.Warningf("Ingress %s has invalid canary weight %s, using 0", ingressKey, value)
+			} else {
+				options.Canary.Weight = weight
+			}
+		}
+	}
+
+	if value, ok := annotations[annotationAffinity]; ok {
+		if value == "cookie" {
+			options.Affinity = &AffinityOptions{
+				CookieName: "INBOUNDAFFINITY",
+				CookieTTL:  c.annotationDuration(ingressKey, annotations, annotationAffinityCookieTTL, 0),
+				CookiePath: "/",
+			}
+			if name := annotations[annotationAffinityCookieName]; name != "" {
+				options.Affinity.CookieName = name
+			}
+			if path := annotations[annotationAffinityCookiePath]; path != "" {
+				options.Affinity.CookiePath = path
+			}
+		} else {
+			c.logger.Warningf("Ingress %s has unknown affinity %s, affinity disabled", ingressKey, value)
+		}
+	}
+
+	if annotations[annotationEnableCORS] == "true" {
+		options.CORS = &CORSOptions{
+			AllowOrigins:     []string{"*"},
+			AllowMethods:     []string{"GET", "PUT", "POST", "DELETE", "PATCH", "OPTIONS"},
+			AllowHeaders:     []string{"DNT", "User-Agent", "X-Requested-With", "If-Modified-Since", "Cache-Control", "Content-Type", "Range", "Authorization"},
+			ExposeHeaders:    []string{},
+			AllowCredentials: annotations[annotationCORSAllowCredentials] == "true",
+			MaxAge:           c.annotationDuration(ingressKey, annotations, annotationCORSMaxAge, 24*time.Hour),
+		}
+		if value, ok := annotations[annotationCORSAllowOrigin]; ok {
+			options.CORS.AllowOrigins = annotationList(value)
+		}
+		if value, ok := annotations[annotationCORSAllowMethods]; ok {
+			options.CORS.AllowMethods = annotationList(strings.ToUpper(value))
+		}
+		if value, ok := annotations[annotationCORSAllowHeaders]; ok {
+			options.CORS.AllowHeaders = annotationList(value)
+		}
+		if value, ok := annotations[annotationCORSExposeHeaders]; ok {
+			options.CORS.ExposeHeaders = annotationList(value)
+		}
+	}
+
+	options.InterceptErrors = annotationBool(annotations, annotationInterceptErrors)
+	options.Compression = annotationBool(annotations, annotationCompression)
+
+	options.HTTPS = HTTPSOptions{
+		Redirect:              annotationBool(annotations, annotationSSLRedirect),
+		RedirectPort:          annotations[annotationSSLRedirectPort],
+		HSTSIncludeSubDomains: annotationBool(annotations, annotationHSTSIncludeSubDomains),
+		HSTSPreload:           annotationBool(annotations, annotationHSTSPreload),
+	}
+	if value, ok := annotations[annotationSSLRedirectStatus]; ok {
+		switch value {
+		case "301", "302", "307", "308":
+			options.HTTPS.RedirectStatus, _ = strconv.Atoi(value)
+		default:
+			c.logger.Warningf("Ingress %s has invalid redirect status %s, using global setting", ingressKey, value)
+		}
+	}
+	if value, ok := annotations[annotationHSTSMaxAge]; ok {
+		maxAge, err := strconv.Atoi(value)
+		if err != nil || maxAge < 0 {
+			c.logger.Warningf("Ingress %s has invalid hsts max age %s, using global setting", ingressKey, value)
+		} else {
+			options.HTTPS.HSTSMaxAge = &maxAge
+		}
+	}
+
+	if value, ok := annotations[annotationBackendProtocol]; ok {
+		switch strings.ToUpper(value) {
+		case BackendProtocolHTTP, BackendProtocolH2C, BackendProtocolGRPC:
+			options.BackendProtocol = strings.ToUpper(value)
+		default:
+			c.logger.Warningf("Ingress %s has unknown backend protocol %s, using %s", ingressKey, value, options.BackendProtocol)
+		}
+	}
+
+	if value, ok := annotations[annotationLoadBalance]; ok {
+		switch value {
+		case balancer.RoundRobin, balancer.LeastConnections, balancer.RandomTwoChoices:
+			options.LoadBalance = value
+		default:
+			c.logger.Warningf("Ingress %s has unknown load balance algorithm %s, using %s", ingressKey, value, options.LoadBalance)
+		}
+	}
+
+	if value, ok := annotations[annotationHealthCheckPath]; ok && value != "" {
+		options.HealthCheck = &HealthCheckOptions{
+			Path:               value,
+			Interval:           c.annotationDuration(ingressKey, annotations, annotationHealthCheckInterval, 10*time.Second),
+			Timeout:            c.annotationDuration(ingressKey, annotations, annotationHealthCheckTimeout, 2*time.Second),
+			UnhealthyThreshold: c.annotationInt(ingressKey, annotations, annotationHealthCheckUnhealthyThreshold, 3),
+			HealthyThreshold:   c.annotationInt(ingressKey, annotations, annotationHealthCheckHealthyThreshold, 2),
+		}
+	}
+
+	if _, ok := annotations[annotationCircuitBreakerThreshold]; ok {
+		options.CircuitBreaker = &CircuitBreakerOptions{
+			Threshold: c.annotationInt(ingressKey, annotations, annotationCircuitBreakerThreshold, 5),
+			Cooldown:  c.annotationDuration(ingressKey, annotations, annotationCircuitBreakerCooldown, 30*time.Second),
+		}
+	}
+
+	if _, ok := annotations[annotationRetryAttempts]; ok {
+		options.Retry = &RetryOptions{
+			Attempts:      c.annotationInt(ingressKey, annotations, annotationRetryAttempts, 1),
+			PerTryTimeout: c.annotationDuration(ingressKey, annotations, annotationRetryPerTryTimeout, 0),
+			StatusCodes:   c.annotationStatusCodes(ingressKey, annotations, annotationRetryOn, []int{502, 503, 504}),
+		}
+	}
+
+	options.Timeouts = TimeoutOptions{
+		Connect:  c.annotationDuration(ingressKey, annotations, annotationConnectTimeout, 0),
+		Response: c.annotationDuration(ingressKey, annotations, annotationResponseTimeout, 0),
+		Idle:     c.annotationDuration(ingressKey, annotations, annotationIdleTimeout, 0),
+		Request:  c.annotationDuration(ingressKey, annotations, annotationRequestTimeout, 0),
+	}
+
+	if _, ok := annotations[annotationRateLimitRPS]; ok {
+		rps := c.annotationFloat(ingressKey, annotations, annotationRateLimitRPS, 10)
+		options.RateLimit = &RateLimitOptions{
+			RequestsPerSecond: rps,
+			Burst:             c.annotationInt(ingressKey, annotations, annotationRateLimitBurst, int(math.Ceil(rps))),
+			Key:               RateLimitKeyIP,
+		}
+
+		value := annotations[annotationRateLimitKey]
+		switch {
+		case value == "" || value == RateLimitKeyIP:
+		case value == RateLimitKeyRoute:
+			options.RateLimit.Key = RateLimitKeyRoute
+		case strings.HasPrefix(value, RateLimitKeyHeader+":") && len(value) > len(RateLimitKeyHeader)+1:
+			options.RateLimit.Key = RateLimitKeyHeader
+			options.RateLimit.Header = strings.TrimPrefix(value, RateLimitKeyHeader+":")
+		default:
+			c.logger.Warningf("Ingress %s has unknown rate limit key %s, using %s", ingressKey, value, RateLimitKeyIP)
+		}
+	}
+
+	if value, ok := annotations[annotationAuthSecret]; ok && value != "" {
+		options.BasicAuth = &BasicAuthOptions{
+			Secret: namespaceFormat(ingress.Namespace, value),
+			Realm:  "Authentication required",
+		}
+		if realm, ok := annotations[annotationAuthRealm]; ok && realm != "" {
+			options.BasicAuth.Realm = realm
+		}
+	}
+
+	if value, ok := annotations[annotationAuthURL]; ok && value != "" {
+		// Invalid urls are kept so requests fail closed
+		if authURL, err := url.Parse(value); err != nil || (authURL.Scheme != "http" && authURL.Scheme != "https") {
+			c.logger.Warningf("Ingress %s has invalid auth url %s, requests will be denied", ingressKey, value)
+		}
+		options.ForwardAuth = &ForwardAuthOptions{
+			URL:             value,
+			RequestHeaders:  []string{"Authorization", "Cookie"},
+			ResponseHeaders: []string{},
+			Timeout:         c.annotationDuration(ingressKey, annotations, annotationAuthTimeout, 5*time.Second),
+		}
+		if value, ok := annotations[annotationAuthRequestHeaders]; ok {
+			options.ForwardAuth.RequestHeaders = annotationList(value)
+		}
+		if value, ok := annotations[annotationAuthResponseHeaders]; ok {
+			options.ForwardAuth.ResponseHeaders = annotationList(value)
+		}
+	}
+
+	switch {
+	case annotations[annotationRewriteRegex] != "":
+		regex, err := regexp.Compile(annotations[annotationRewriteRegex])
+		if err != nil {
+			c.logger.Warningf("Ingress %s has invalid rewrite regex %s, %s", ingressKey, annotations[annotationRewriteRegex], err)
+			break
+		}
+		options.Rewrite = &RewriteOptions{Regex: regex, Target: annotations[annotationRewriteTarget]}
+	case annotations[annotationRewritePrefix] != "":
+		options.Rewrite = &RewriteOptions{Prefix: annotations[annotationRewritePrefix]}
+	case annotations[annotationRewriteStripPrefix] == "true":
+		options.Rewrite = &RewriteOptions{StripPrefix: true}
+	}
+
+	if value, ok := annotations[annotationMatchType]; ok {
+		options.MatchType = c.annotationMatchType(ingressKey, value)
+	}
+	for path, value := range annotationPathValues(annotations[annotationPathMatchType]) {
+		if options.PathMatchTypes == nil {
+			options.PathMatchTypes = map[string]string{}
+		}
+		options.PathMatchTypes[path] = c.annotationMatchType(ingressKey, value)
+	}
+
+	options.SourceRange = c.annotationSourceRange(ingressKey, annotations[annotationAllowSourceRange], annotations[annotationDenySourceRange])
+	allowPaths := annotationPathValues(annotations[annotationPathAllowSourceRange])
+	denyPaths := annotationPathValues(annotations[annotationPathDenySourceRange])
+	for _, paths := range []map[string]string{allowPaths, denyPaths} {
+		for path := range paths {
+			// Paths without an override of their own inherit the ingress range
+			allow, ok := allowPaths[path]
+			if !ok {
+				allow = annotations[annotationAllowSourceRange]
+			}
+			deny, ok := denyPaths[path]
+			if !ok {
+				deny = annotations[annotationDenySourceRange]
+			}
+			if options.PathSourceRanges == nil {
+				options.PathSourceRanges = map[string]SourceRangeOptions{}
+			}
+			options.PathSourceRanges[path] = c.annotationSourceRange(ingressKey, allow, deny)
+		}
+	}
+
+	return options
+}
+
+// ForPath returns options with path specific overrides applied
+func (o *IngressOptions) ForPath(path string) *IngressOptions {
+	pathRange, rangeOk := o.PathSourceRanges[path]
+	matchType, matchOk := o.PathMatchTypes[path]
+	if !rangeOk && !matchOk {
+		return o
+	}
+	options := *o
+	if rangeOk {
+		options.SourceRange = pathRange
+	}
+	if matchOk {
+		options.MatchType = matchType
+	}
+	return &options
+}
+
+// annotationMatchType validates a path match type, unknown types use prefix
+func (c *Controller) annotationMatchType(ingressKey string, value string) string {
+	switch strings.TrimSpace(value) {
+	case MatchExact, MatchPrefix, MatchRegex:
+		return strings.TrimSpace(value)
+	case matchImplementationSpecific:
+		return MatchPrefix
+	default:
+		c.logger.Warningf("Ingress %s has unknown match type %s, using %s", ingressKey, value, MatchPrefix)
+		return MatchPrefix
+	}
+}
+
+// annotationSourceRange parses allow and deny CIDR lists
+// invalid values fail closed by allowing no addresses
+func (c *Controller) annotationSourceRange(ingressKey string, allow string, deny string) SourceRangeOptions {
+	sourceRange := SourceRangeOptions{}
+	for _, item := range []struct {
+		value    string
+		networks *[]*net.IPNet
+	}{{allow, &sourceRange.Allow}, {deny, &sourceRange.Deny}} {
+		if strings.TrimSpace(item.value) == "" {
+			continue
+		}
+		networks, err := helpers.ParseCIDRs(item.value)
+		if err != nil {
+			c.logger.Warningf("Ingress %s has invalid source range, %s, denying all addresses", ingressKey, err)
+			return SourceRangeOptions{Allow: []*net.IPNet{}}
+		}
+		*item.networks = networks
+	}
+	return sourceRange
+}
+
+// annotationList splits a comma separated list, ignoring empty items
+func annotationList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// annotationPathValues splits /path=value;/other=value into a map
+func annotationPathValues(value string) map[string]string {
+	values := map[string]string{}
+	for _, item := range strings.Split(value, ";") {
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			continue
+		}
+		values[strings.TrimSpace(parts[0])] = parts[1]
+	}
+	return values
+}
+
+// annotationBool returns nil when annotation is missing so a global setting applies
+func annotationBool(annotations map[string]string, key string) *bool {
+	value, ok := annotations[key]
+	if !ok {
+		return nil
+	}
+	enabled := value == "true"
+	return &enabled
+}
+
+// annotationDuration parses a positive duration, invalid values are logged and use fallback
+func (c *Controller) annotationDuration(ingressKey string, annotations map[string]string, key string, fallback time.Duration) time.Duration {
+	value, ok := annotations[key]
+	if !ok {
+		return fallback
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		c.logger.Warningf("Ingress %s has invalid duration %s for %s, using %s", ingressKey, value, key, fallback)
+		return fallback
+	}
+	return duration
+}
+
+// annotationInt parses a positive integer, invalid values are logged and use fallback
+func (c *Controller) annotationInt(ingressKey string, annotations map[string]string, key string, fallback int) int {
+	value, ok := annotations[key]
+	if !ok {
+		return fallback
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number <= 0 {
+		c.logger.Warningf("Ingress %s has invalid number %s for %s, using %v", ingressKey, value, key, fallback)
+		return fallback
+	}
+	return number
+}
+
+// annotationFloat parses a positive number, invalid values are logged and use fallback
+func (c *Controller) annotationFloat(ingressKey string, annotations map[string]string, key string, fallback float64) float64 {
+	value, ok := annotations[key]
+	if !ok {
+		return fallback
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || number <= 0 {
+		c.logger.Warningf("Ingress %s has invalid number %s for %s, using %v", ingressKey, value, key, fallback)
+		return fallback
+	}
+	return number
+}
+
+// annotationStatusCodes parses a comma separated list of http status codes
+func (c *Controller) annotationStatusCodes(ingressKey string, annotations map[string]string, key string, fallback []int) []int {
+	value, ok := annotations[key]
+	if !ok {
+		return fallback
+	}
+
+	codes := make([]int, 0)
+	for _, item := range strings.Split(value, ",") {
+		code, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil || code < 100 || code > 599 {
+			c.logger.Warningf("Ingress %s has invalid status codes %s for %s, using %v", ingressKey, value, key, fallback)
+			return fallback
+		}
+		codes = append(codes, code)
+	}
+	return codes
+}
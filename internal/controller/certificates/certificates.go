@@ -3,8 +3,10 @@ package certificates
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/elijahglover/inbound/internal/logger"
 	v1 "k8s.io/api/core/v1"
@@ -162,6 +164,16 @@ func (w *CertificateWatcher) parseCertificate(data map[string][]byte) (*tls.Cert
 	certificate, err := tls.X509KeyPair(data[tlsCertificate], data[tlsPrivateKey])
 	if err != nil {
 		w.logger.Errorf("Unable to parse certificate %s", err)
+		return &certificate, err
 	}
-	return &certificate, err
+
+	// Keep parsed leaf so expiry can be tracked
+	leaf, err := x509.ParseCertificate(certificate.Certificate[0])
+	if err != nil {
+		w.logger.Errorf("Unable to parse leaf certificate %s", err)
+		return &certificate, err
+	}
+	certificate.Leaf = leaf
+	w.logger.Verbosef("Loaded certificate %s/%s expiring %s", w.namespaceName, w.secretName, leaf.NotAfter.Format(time.RFC3339))
+	return &certificate, nil
 }
@@ -0,0 +1,138 @@
+package configmaps
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/elijahglover/inbound/internal/logger"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+)
+
+var errChannelClosed = fmt.Errorf("Closed listening channel")
+
+// ConfigMapWatcher watches cluster
+type ConfigMapWatcher struct {
+	client                          *kubernetes.Clientset
+	logger                          logger.Logger
+	namespaceName                   string
+	configMapName                   string
+	configMapChangedSubscribers     map[string]chan<- map[string]string
+	configMapChangedSubscribersLock *sync.Mutex
+	configMapDeletedSubscribers     map[string]chan<- bool
+	configMapDeletedSubscribersLock *sync.Mutex
+}
+
+// New ConfigMapWatcher
+func New(logger logger.Logger, client *kubernetes.Clientset, namespaceName string, configMapName string) *ConfigMapWatcher {
+	return &ConfigMapWatcher{
+		client:                          client,
+		logger:                          logger,
+		namespaceName:                   namespaceName,
+		configMapName:                   configMapName,
+		configMapChangedSubscribers:     map[string]chan<- map[string]string{},
+		configMapChangedSubscribersLock: &sync.Mutex{},
+		configMapDeletedSubscribers:     map[string]chan<- bool{},
+		configMapDeletedSubscribersLock: &sync.Mutex{},
+	}
+}
+
+// SubscribeConfigMapChanged adds channel
+func (w *ConfigMapWatcher) SubscribeConfigMapChanged(source string, add chan<- map[string]string) {
+	w.configMapChangedSubscribersLock.Lock()
+	defer w.configMapChangedSubscribersLock.Unlock()
+	w.configMapChangedSubscribers[source] = add
+}
+
+// SubscribeConfigMapDeleted adds channel
+func (w *ConfigMapWatcher) SubscribeConfigMapDeleted(source string, add chan<- bool) {
+	w.configMapDeletedSubscribersLock.Lock()
+	defer w.configMapDeletedSubscribersLock.Unlock()
+	w.configMapDeletedSubscribers[source] = add
+}
+
+func (w *ConfigMapWatcher) publishConfigMapChanged(data map[string]string) {
+	w.configMapChangedSubscribersLock.Lock()
+	defer w.configMapChangedSubscribersLock.Unlock()
+
+	if len(w.configMapChangedSubscribers) == 0 {
+		return
+	}
+
+	for _, ch := range w.configMapChangedSubscribers {
+		ch <- data
+	}
+}
+
+func (w *ConfigMapWatcher) publishConfigMapDeleted(value bool) {
+	w.configMapDeletedSubscribersLock.Lock()
+	defer w.configMapDeletedSubscribersLock.Unlock()
+
+	if len(w.configMapDeletedSubscribers) == 0 {
+		return
+	}
+
+	for _, ch := range w.configMapDeletedSubscribers {
+		ch <- value
+	}
+}
+
+// Watch for change in cluster
+func (w *ConfigMapWatcher) Watch(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+			err := w.watchConfigMap(ctx, w.namespaceName, w.configMapName)
+			if err != nil && err != errChannelClosed {
+				w.logger.Errorf("ConfigMap watch returned error %s", err)
+				return err
+			}
+		}
+	}
+}
+
+func (w *ConfigMapWatcher) watchConfigMap(ctx context.Context, namespace string, configMapName string) error {
+	configMapNamespace := w.client.Core().ConfigMaps(namespace)
+
+	configMapChanges, err := configMapNamespace.Watch(meta_v1.ListOptions{
+		FieldSelector: fmt.Sprintf("metadata.name=%s", configMapName),
+	})
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			configMapChanges.Stop()
+			return nil
+		case event, ok := <-configMapChanges.ResultChan():
+			if !ok {
+				return errChannelClosed
+			}
+			w.processEvent(event, namespace, configMapName)
+		}
+	}
+}
+
+func (w *ConfigMapWatcher) processEvent(event watch.Event, namespace string, configMapName string) {
+	if event.Object == nil {
+		w.logger.Verbosef("Received empty payload watching configmap, type %s in %s/%s", event.Type, namespace, configMapName)
+		return
+	}
+	configMap := event.Object.(*v1.ConfigMap)
+	if event.Type == watch.Added || event.Type == watch.Modified {
+		w.publishConfigMapChanged(configMap.Data)
+		return
+	}
+	if event.Type == watch.Deleted {
+		w.publishConfigMapDeleted(true)
+		return
+	}
+	w.logger.Verbosef("Received unknown message type %s watching configmap %s/%s", event.Type, namespace, configMapName)
+}
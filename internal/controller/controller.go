@@ -5,6 +5,7 @@ import (
 	"crypto/tls"
 	"sync"
 
+	"github.com/elijahglover/inbound/internal/errorpages"
 	"github.com/elijahglover/inbound/internal/logger"
 	"k8s.io/client-go/kubernetes"
 )
@@ -20,12 +21,28 @@ type Controller struct {
 	// TLS active certificates - Key = hostname and value is certificate with metadata
 	certificatesSecretMap     map[string]string
 	certificatesSecretMapLock *sync.Mutex
+	// TLS secrets referenced by ingresses - key = ingress name, value is secrets keyed by secret name
+	tlsSecrets     map[string]map[string]*TLSSecret
+	tlsSecretsLock *sync.Mutex
+	// Basic auth credentials - key = secret name, value is user to password hash
+	credentials     map[string]map[string]string
+	credentialsLock *sync.Mutex
 	// Registry of all services defined, key is service name, value is service metadata
 	services     map[string]*Service
 	servicesLock *sync.Mutex
+	// Registry of ready endpoints, key is service name, value is endpoint addresses
+	endpoints     map[string]*Endpoints
+	endpointsLock *sync.Mutex
 	// Route table, key is hostname, value is route table
 	routeTable     map[string]*RouteTable
 	routeTableLock *sync.Mutex
+	// Catch all routes, key is ingress name for ingresses with only spec.backend, default backend is from config
+	defaultRoutes     map[string]*RoutePath
+	defaultBackend    *RoutePath
+	defaultRoutesLock *sync.Mutex
+	// Error page templates from configmap
+	errorPages     *errorpages.Pages
+	errorPagesLock *sync.Mutex
 	// Namespace - required for duplicate events fired by k8s
 	namespaceHandles     map[string]context.CancelFunc
 	namespaceHandlesLock *sync.Mutex
@@ -44,13 +61,44 @@ func New(logger logger.Logger, targetNamespace string, client *kubernetes.Client
 		certificatesLock:          &sync.Mutex{},
 		certificatesSecretMap:     map[string]string{},
 		certificatesSecretMapLock: &sync.Mutex{},
+		tlsSecrets:                map[string]map[string]*TLSSecret{},
+		tlsSecretsLock:            &sync.Mutex{},
+		credentials:               map[string]map[string]string{},
+		credentialsLock:           &sync.Mutex{},
 		services:                  map[string]*Service{},
 		servicesLock:              &sync.Mutex{},
+		endpoints:                 map[string]*Endpoints{},
+		endpointsLock:             &sync.Mutex{},
 		routeTable:                map[string]*RouteTable{},
 		routeTableLock:            &sync.Mutex{},
+		defaultRoutes:             map[string]*RoutePath{},
+		defaultRoutesLock:         &sync.Mutex{},
+		errorPagesLock:            &sync.Mutex{},
 		namespaceHandles:          map[string]context.CancelFunc{},
 		namespaceHandlesLock:      &sync.Mutex{},
 		ingressHandles:            map[string]context.CancelFunc{},
 		ingressHandlesLock:        &sync.Mutex{},
 	}
 }
+
+// MonitorDefaultBackend routes traffic matching no ingress to service
+func (c *Controller) MonitorDefaultBackend(ctx context.Context, namespaceName string, serviceName string, port int32) {
+	c.defaultRoutesLock.Lock()
+	c.defaultBackend = &RoutePath{
+		Path:        "/",
+		ServiceName: namespaceFormat(namespaceName, serviceName),
+		ServicePort: port,
+		Match:       MatchPrefix,
+		Options:     defaultIngressOptions(),
+	}
+	c.defaultRoutesLock.Unlock()
+
+	c.logger.Infof("Using default backend %s:%v", namespaceFormat(namespaceName, serviceName), port)
+	go c.monitorService(ctx, namespaceName, serviceName)
+	go c.monitorEndpoints(ctx, namespaceName, serviceName)
+}
+
+// MonitorErrorPages loads error page templates from configmap
+func (c *Controller) MonitorErrorPages(ctx context.Context, namespaceName string, configMapName string) {
+	go c.monitorConfigMap(ctx, namespaceName, configMapName)
+}
@@ -0,0 +1,140 @@
+package endpoints
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/elijahglover/inbound/internal/logger"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+)
+
+var errChannelClosed = fmt.Errorf("Closed listening channel")
+
+// EndpointsWatcher watches cluster
+type EndpointsWatcher struct {
+	client                          *kubernetes.Clientset
+	logger                          logger.Logger
+	namespaceName                   string
+	serviceName                     string
+	endpointsChangedSubscribers     map[string]chan<- *v1.Endpoints
+	endpointsChangedSubscribersLock *sync.Mutex
+	endpointsDeletedSubscribers     map[string]chan<- string
+	endpointsDeletedSubscribersLock *sync.Mutex
+}
+
+// New EndpointsWatcher
+func New(logger logger.Logger, client *kubernetes.Clientset, namespaceName string, serviceName string) *EndpointsWatcher {
+	return &EndpointsWatcher{
+		client:                          client,
+		logger:                          logger,
+		namespaceName:                   namespaceName,
+		serviceName:                     serviceName,
+		endpointsChangedSubscribers:     map[string]chan<- *v1.Endpoints{},
+		endpointsChangedSubscribersLock: &sync.Mutex{},
+		endpointsDeletedSubscribers:     map[string]chan<- string{},
+		endpointsDeletedSubscribersLock: &sync.Mutex{},
+	}
+}
+
+// Watch for endpoints change in cluster
+func (w *EndpointsWatcher) Watch(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+			err := w.watchEndpoints(ctx, w.namespaceName, w.serviceName)
+			if err != nil && err != errChannelClosed {
+				w.logger.Errorf("Endpoints watch returned error %s", err)
+				return err
+			}
+		}
+	}
+}
+
+// SubscribeEndpointsChanged adds channel
+func (w *EndpointsWatcher) SubscribeEndpointsChanged(source string, add chan<- *v1.Endpoints) {
+	w.endpointsChangedSubscribersLock.Lock()
+	defer w.endpointsChangedSubscribersLock.Unlock()
+	w.endpointsChangedSubscribers[source] = add
+}
+
+// SubscribeEndpointsDeleted adds channel
+func (w *EndpointsWatcher) SubscribeEndpointsDeleted(source string, add chan<- string) {
+	w.endpointsDeletedSubscribersLock.Lock()
+	defer w.endpointsDeletedSubscribersLock.Unlock()
+	w.endpointsDeletedSubscribers[source] = add
+}
+
+func (w *EndpointsWatcher) publishEndpointsChanged(endpoints *v1.Endpoints) {
+	w.endpointsChangedSubscribersLock.Lock()
+	defer w.endpointsChangedSubscribersLock.Unlock()
+
+	if len(w.endpointsChangedSubscribers) == 0 {
+		return
+	}
+
+	for _, ch := range w.endpointsChangedSubscribers {
+		ch <- endpoints
+	}
+}
+
+func (w *EndpointsWatcher) publishEndpointsDeleted(name string) {
+	w.endpointsDeletedSubscribersLock.Lock()
+	defer w.endpointsDeletedSubscribersLock.Unlock()
+
+	if len(w.endpointsDeletedSubscribers) == 0 {
+		return
+	}
+
+	for _, ch := range w.endpointsDeletedSubscribers {
+		ch <- name
+	}
+}
+
+func (w *EndpointsWatcher) watchEndpoints(ctx context.Context, namespace string, serviceName string) error {
+	endpointsNamespace := w.client.Core().Endpoints(namespace)
+
+	// Endpoints share the name of the service they belong to
+	endpointsChanges, err := endpointsNamespace.Watch(meta_v1.ListOptions{
+		FieldSelector: fmt.Sprintf("metadata.name=%s", serviceName),
+	})
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			endpointsChanges.Stop()
+			return nil
+		case event, ok := <-endpointsChanges.ResultChan():
+			if !ok {
+				return errChannelClosed
+			}
+			w.processEvent(event, namespace, serviceName)
+		}
+	}
+}
+
+func (w *EndpointsWatcher) processEvent(event watch.Event, namespace string, serviceName string) {
+	if event.Object == nil {
+		w.logger.Verbosef("Received empty payload watching endpoints, %s type in %s/%s", event.Type, namespace, serviceName)
+		return
+	}
+
+	endpoints := event.Object.(*v1.Endpoints)
+	if event.Type == watch.Added || event.Type == watch.Modified {
+		w.publishEndpointsChanged(endpoints)
+		return
+	}
+	if event.Type == watch.Deleted {
+		w.publishEndpointsDeleted(endpoints.Name)
+		return
+	}
+	w.logger.Verbosef("Received unknown message type %s watching endpoints %s/%s", event.Type, namespace, serviceName)
+}
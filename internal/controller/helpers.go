@@ -2,33 +2,86 @@ package controller
 
 import (
 	"fmt"
+	"regexp"
 	"sort"
+	"strings"
 )
 
 func matchRoutePath(paths []RoutePath, matchPath string) *RoutePath {
-	for _, path := range paths {
-		if path.Path == matchPath {
-			return &path
+	for i := range paths {
+		if paths[i].Path == matchPath {
+			return &paths[i]
 		}
 	}
 	return nil
 }
 
-func deleteRoutePath(paths []RoutePath, matchPath string) []RoutePath {
+// deleteRoutePath removes path when it is still owned by ingress
+func deleteRoutePath(paths []RoutePath, matchPath string, ingressKey string) []RoutePath {
 	for i, path := range paths {
-		if path.Path == matchPath {
+		if path.Path == matchPath && path.Ingress == ingressKey {
 			return append(paths[:i], paths[i+1:]...)
 		}
 	}
 	return paths
 }
 
-func sortRulePathsLength(routes []RoutePath) {
-	sort.Slice(routes, func(i, j int) bool {
-		return len(routes[i].Path) > len(routes[j].Path)
+// Matches checks if request path is matched by route
+func (r *RoutePath) Matches(path string) bool {
+	switch r.Match {
+	case MatchExact:
+		return path == r.Path
+	case MatchRegex:
+		return r.Regex != nil && r.Regex.MatchString(path)
+	default:
+		// Prefix matches whole path segments, /api matches /api/users but not /apiary
+		prefix := strings.TrimSuffix(r.Path, "/")
+		return prefix == "" || path == prefix || strings.HasPrefix(path, prefix+"/")
+	}
+}
+
+// Remainder returns the part of path after the portion matched by route
+func (r *RoutePath) Remainder(path string) string {
+	if r.Match == MatchRegex {
+		if r.Regex == nil {
+			return path
+		}
+		if loc := r.Regex.FindStringIndex(path); loc != nil {
+			return path[loc[1]:]
+		}
+		return path
+	}
+	return strings.TrimPrefix(path, strings.TrimSuffix(r.Path, "/"))
+}
+
+// compileRouteRegex anchors route path at the start of the request path
+func compileRouteRegex(path string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + path + ")")
+}
+
+// sortRulePaths orders exact matches first then longest paths, prefix before regex for equal lengths
+func sortRulePaths(routes []RoutePath) {
+	sort.SliceStable(routes, func(i, j int) bool {
+		iExact, jExact := routes[i].Match == MatchExact, routes[j].Match == MatchExact
+		if iExact != jExact {
+			return iExact
+		}
+		if len(routes[i].Path) != len(routes[j].Path) {
+			return len(routes[i].Path) > len(routes[j].Path)
+		}
+		return routes[i].Match != MatchRegex && routes[j].Match == MatchRegex
 	})
 }
 
+// wildcardHost replaces the first label of host, wildcards only match a single label
+func wildcardHost(host string) string {
+	index := strings.Index(host, ".")
+	if index <= 0 {
+		return ""
+	}
+	return "*" + host[index:]
+}
+
 func namespaceFormat(namespace string, resourceName string) string {
 	return fmt.Sprintf("%s/%s", namespace, resourceName)
 }
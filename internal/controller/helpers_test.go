@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"regexp"
+	"testing"
+)
+
+func Test_matchRoutePath_UpdatesExistingPath(t *testing.T) {
+	paths := []RoutePath{
+		{Path: "/", Options: &IngressOptions{LoadBalance: "round-robin"}},
+		{Path: "/api", Options: &IngressOptions{LoadBalance: "round-robin"}},
+	}
+
+	matchedPath := matchRoutePath(paths, "/api")
+	if matchedPath == nil {
+		t.Fatalf("expected path to match")
+	}
+	matchedPath.Options = &IngressOptions{LoadBalance: "least-connections"}
+
+	if actual := paths[1].Options.LoadBalance; actual != "least-connections" {
+		t.Fatalf("unexpected output %s", actual)
+	}
+	if actual := paths[0].Options.LoadBalance; actual != "round-robin" {
+		t.Fatalf("unexpected output %s", actual)
+	}
+	if matchRoutePath(paths, "/missing") != nil {
+		t.Fatalf("expected no match")
+	}
+}
+
+func Test_RoutePath_Matches(t *testing.T) {
+	prefix := RoutePath{Path: "/api/", Match: MatchPrefix}
+	exact := RoutePath{Path: "/api", Match: MatchExact}
+	regex := RoutePath{Path: "/v[0-9]+/", Match: MatchRegex, Regex: regexp.MustCompile("^(?:/v[0-9]+/)")}
+	root := RoutePath{Path: "/", Match: MatchPrefix}
+
+	cases := []struct {
+		route    RoutePath
+		path     string
+		expected bool
+	}{
+		{prefix, "/api", true},
+		{prefix, "/api/users", true},
+		{prefix, "/apiary", false},
+		{exact, "/api", true},
+		{exact, "/api/", false},
+		{regex, "/v2/users", true},
+		{regex, "/api/v2/", false},
+		{root, "/anything", true},
+	}
+	for _, c := range cases {
+		if actual := c.route.Matches(c.path); actual != c.expected {
+			t.Fatalf("unexpected output for %s %s %v %v", c.route.Path, c.path, actual, c.expected)
+		}
+	}
+}
+
+func Test_RoutePath_Remainder(t *testing.T) {
+	prefix := RoutePath{Path: "/api/", Match: MatchPrefix}
+	exact := RoutePath{Path: "/api", Match: MatchExact}
+	regex := RoutePath{Path: "/api/v[0-9]+", Match: MatchRegex, Regex: regexp.MustCompile("^(?:/api/v[0-9]+)")}
+
+	cases := []struct {
+		route    RoutePath
+		path     string
+		expected string
+	}{
+		{prefix, "/api/users", "/users"},
+		{prefix, "/api", ""},
+		{exact, "/api", ""},
+		{regex, "/api/v2/users", "/users"},
+		{regex, "/api/v10", ""},
+		{regex, "/other", "/other"},
+	}
+	for _, c := range cases {
+		if actual := c.route.Remainder(c.path); actual != c.expected {
+			t.Fatalf("unexpected output for %s %s %s %s", c.route.Path, c.path, actual, c.expected)
+		}
+	}
+}
+
+func Test_wildcardHost(t *testing.T) {
+	for input, expected := range map[string]string{
+		"a.example.com":   "*.example.com",
+		"a.b.example.com": "*.b.example.com",
+		"example.com":     "*.com",
+		"localhost":       "",
+		".example.com":    "",
+		"":                "",
+	} {
+		if actual := wildcardHost(input); actual != expected {
+			t.Fatalf("unexpected output for %s %s %s", input, actual, expected)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package htpasswd
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/elijahglover/inbound/internal/logger"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+)
+
+var errChannelClosed = fmt.Errorf("Closed listening channel")
+
+// secretKey holds htpasswd formatted credentials
+const secretKey = "auth"
+
+// CredentialsWatcher watches cluster
+type CredentialsWatcher struct {
+	client                            *kubernetes.Clientset
+	logger                            logger.Logger
+	namespaceName                     string
+	secretName                        string
+	credentialsChangedSubscribers     map[string]chan<- map[string]string
+	credentialsChangedSubscribersLock *sync.Mutex
+	credentialsDeletedSubscribers     map[string]chan<- bool
+	credentialsDeletedSubscribersLock *sync.Mutex
+}
+
+// New CredentialsWatcher
+func New(logger logger.Logger, client *kubernetes.Clientset, namespaceName string, secretName string) *CredentialsWatcher {
+	return &CredentialsWatcher{
+		client:                            client,
+		logger:                            logger,
+		namespaceName:                     namespaceName,
+		secretName:                        secretName,
+		credentialsChangedSubscribers:     map[string]chan<- map[string]string{},
+		credentialsChangedSubscribersLock: &sync.Mutex{},
+		credentialsDeletedSubscribers:     map[string]chan<- bool{},
+		credentialsDeletedSubscribersLock: &sync.Mutex{},
+	}
+}
+
+// SubscribeCredentialsChanged adds channel
+func (w *CredentialsWatcher) SubscribeCredentialsChanged(source string, add chan<- map[string]string) {
+	w.credentialsChangedSubscribersLock.Lock()
+	defer w.credentialsChangedSubscribersLock.Unlock()
+	w.credentialsChangedSubscribers[source] = add
+}
+
+// SubscribeCredentialsDeleted adds channel
+func (w *CredentialsWatcher) SubscribeCredentialsDeleted(source string, add chan<- bool) {
+	w.credentialsDeletedSubscribersLock.Lock()
+	defer w.credentialsDeletedSubscribersLock.Unlock()
+	w.credentialsDeletedSubscribers[source] = add
+}
+
+func (w *CredentialsWatcher) publishCredentialsChanged(credentials map[string]string) {
+	w.credentialsChangedSubscribersLock.Lock()
+	defer w.credentialsChangedSubscribersLock.Unlock()
+
+	if len(w.credentialsChangedSubscribers) == 0 {
+		return
+	}
+
+	for _, ch := range w.credentialsChangedSubscribers {
+		ch <- credentials
+	}
+}
+
+func (w *CredentialsWatcher) publishCredentialsDeleted(value bool) {
+	w.credentialsDeletedSubscribersLock.Lock()
+	defer w.credentialsDeletedSubscribersLock.Unlock()
+
+	if len(w.credentialsDeletedSubscribers) == 0 {
+		return
+	}
+
+	for _, ch := range w.credentialsDeletedSubscribers {
+		ch <- value
+	}
+}
+
+// Watch for change in cluster
+func (w *CredentialsWatcher) Watch(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+			err := w.watchSecret(ctx, w.namespaceName, w.secretName)
+			if err != nil && err != errChannelClosed {
+				w.logger.Errorf("Credentials watch returned error %s", err)
+				return err
+			}
+		}
+	}
+}
+
+func (w *CredentialsWatcher) watchSecret(ctx context.Context, namespace string, secretName string) error {
+	secretNamespace := w.client.Core().Secrets(namespace)
+
+	secretChanges, err := secretNamespace.Watch(meta_v1.ListOptions{
+		FieldSelector: fmt.Sprintf("metadata.name=%s", secretName),
+	})
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			secretChanges.Stop()
+			return nil
+		case event, ok := <-secretChanges.ResultChan():
+			if !ok {
+				return errChannelClosed
+			}
+			w.processEvent(event, namespace, secretName)
+		}
+	}
+}
+
+func (w *CredentialsWatcher) processEvent(event watch.Event, namespace string, secretName string) {
+	if event.Object == nil {
+		w.logger.Verbosef("Received empty payload watching secret, type %s in %s/%s", event.Type, namespace, secretName)
+		return
+	}
+	secret := event.Object.(*v1.Secret)
+	if event.Type == watch.Added || event.Type == watch.Modified {
+		data, ok := secret.Data[secretKey]
+		if !ok {
+			w.logger.Errorf("Missing %s from secret %s/%s", secretKey, namespace, secretName)
+			return
+		}
+
+		credentials, invalid := Parse(data)
+		for _, line := range invalid {
+			w.logger.Warningf("Ignoring invalid credentials on line %v in secret %s/%s", line, namespace, secretName)
+		}
+		w.logger.Verbosef("Loaded %v credentials from secret %s/%s", len(credentials), namespace, secretName)
+		w.publishCredentialsChanged(credentials)
+		return
+	}
+	if event.Type == watch.Deleted {
+		w.publishCredentialsDeleted(true)
+		return
+	}
+	w.logger.Verbosef("Received unknown message type %s watching secret %s/%s", event.Type, namespace, secretName)
+}
@@ -0,0 +1,55 @@
+package htpasswd
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const shaPrefix = "{SHA}"
+
+// Parse htpasswd data into a map of user to password hash, returns line numbers of unsupported entries
+func Parse(data []byte) (map[string]string, []int) {
+	credentials := map[string]string{}
+	invalid := []int{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(text, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || !supported(parts[1]) {
+			invalid = append(invalid, line)
+			continue
+		}
+		credentials[parts[0]] = parts[1]
+	}
+	return credentials, invalid
+}
+
+// Verify password against a bcrypt or SHA hash
+func Verify(hash string, password string) bool {
+	if strings.HasPrefix(hash, shaPrefix) {
+		sum := sha1.Sum([]byte(password))
+		expected := base64.StdEncoding.EncodeToString(sum[:])
+		return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(hash, shaPrefix)), []byte(expected)) == 1
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+func supported(hash string) bool {
+	return strings.HasPrefix(hash, shaPrefix) ||
+		strings.HasPrefix(hash, "$2a$") ||
+		strings.HasPrefix(hash, "$2b$") ||
+		strings.HasPrefix(hash, "$2y$")
+}
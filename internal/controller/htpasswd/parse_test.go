@@ -0,0 +1,35 @@
+package htpasswd_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/elijahglover/inbound/internal/controller/htpasswd"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func Test_Htpasswd_Verify(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	// htpasswd writes bcrypt hashes with the $2y$ prefix
+	apacheHash := "$2y$" + strings.TrimPrefix(string(hash), "$2a$")
+
+	data := "# staging users\n" +
+		"alice:" + apacheHash + "\n" +
+		"bob:{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=\n" +
+		"carol:$apr1$unsupported\n"
+
+	credentials, invalid := htpasswd.Parse([]byte(data))
+	if len(credentials) != 2 || len(invalid) != 1 || invalid[0] != 4 {
+		t.Fatalf("unexpected parse output %v %v", credentials, invalid)
+	}
+
+	if !htpasswd.Verify(credentials["alice"], "secret") || htpasswd.Verify(credentials["alice"], "wrong") {
+		t.Fatalf("unexpected bcrypt verification")
+	}
+	if !htpasswd.Verify(credentials["bob"], "password") || htpasswd.Verify(credentials["bob"], "wrong") {
+		t.Fatalf("unexpected sha verification")
+	}
+}
@@ -1,19 +1,187 @@
 package controller
 
-import "crypto/tls"
+import (
+	"crypto/tls"
+	"net"
+	"regexp"
+	"time"
+)
 
 // RouteTable represents a hostname from ingress
 type RouteTable struct {
 	Ingress string
 	Host    string
 	Paths   []RoutePath
+	// Default route from ingress spec.backend for unmatched paths
+	Default *RoutePath
+	// Canaries from canary ingresses, key is path
+	Canaries map[string][]RouteCanary
 }
 
 // RoutePath represents a single route mapped to service
 type RoutePath struct {
+	Ingress     string
 	Path        string
 	ServiceName string
 	ServicePort int32
+	// Match is one of Exact, Prefix or Regex
+	Match   string
+	Regex   *regexp.Regexp
+	Options *IngressOptions
+	// Canaries receive a share of traffic instead of ServiceName
+	Canaries []RouteCanary
+}
+
+// RouteCanary represents a canary service for a route path
+type RouteCanary struct {
+	Ingress     string
+	ServiceName string
+	ServicePort int32
+	Options     *CanaryOptions
+}
+
+// IngressOptions represents behaviour configured through ingress annotations
+type IngressOptions struct {
+	ACME            bool
+	AccessLog       bool
+	BackendProtocol string
+	LoadBalance     string
+	HealthCheck     *HealthCheckOptions
+	CircuitBreaker  *CircuitBreakerOptions
+	Retry           *RetryOptions
+	Timeouts        TimeoutOptions
+	RateLimit       *RateLimitOptions
+	SourceRange     SourceRangeOptions
+	BasicAuth       *BasicAuthOptions
+	ForwardAuth     *ForwardAuthOptions
+	Rewrite         *RewriteOptions
+	MatchType       string
+	HTTPS           HTTPSOptions
+	Canary          *CanaryOptions
+	Affinity        *AffinityOptions
+	CORS            *CORSOptions
+	// Compression overrides the global setting for compressing responses
+	Compression *bool
+	// InterceptErrors overrides the global setting for replacing upstream 5xx with error pages
+	InterceptErrors *bool
+	// PathSourceRanges overrides SourceRange for individual paths
+	PathSourceRanges map[string]SourceRangeOptions
+	// PathMatchTypes overrides MatchType for individual paths
+	PathMatchTypes map[string]string
+}
+
+// HealthCheckOptions represents active health checking of upstream endpoints
+type HealthCheckOptions struct {
+	Path               string
+	Interval           time.Duration
+	Timeout            time.Duration
+	UnhealthyThreshold int
+	HealthyThreshold   int
+}
+
+// CircuitBreakerOptions represents passive failure detection of upstream endpoints
+type CircuitBreakerOptions struct {
+	Threshold int
+	Cooldown  time.Duration
+}
+
+// RetryOptions represents retrying failed upstream attempts for idempotent requests
+type RetryOptions struct {
+	Attempts      int
+	PerTryTimeout time.Duration
+	StatusCodes   []int
+}
+
+// TimeoutOptions represents per ingress timeouts, zero values use global configuration
+type TimeoutOptions struct {
+	Connect  time.Duration
+	Response time.Duration
+	Idle     time.Duration
+	Request  time.Duration
+}
+
+// RateLimitOptions represents token bucket rate limiting of requests
+type RateLimitOptions struct {
+	RequestsPerSecond float64
+	Burst             int
+	// Key is one of ip, header or route
+	Key string
+	// Header name when key is header
+	Header string
+}
+
+// SourceRangeOptions restricts client addresses, nil lists are unrestricted
+type SourceRangeOptions struct {
+	Allow []*net.IPNet
+	Deny  []*net.IPNet
+}
+
+// BasicAuthOptions represents basic authentication against htpasswd credentials
+type BasicAuthOptions struct {
+	// Secret is namespace/name of the credentials secret
+	Secret string
+	Realm  string
+}
+
+// ForwardAuthOptions represents authorization of requests by an external service
+type ForwardAuthOptions struct {
+	URL string
+	// RequestHeaders copied from the client request to the auth request
+	RequestHeaders []string
+	// ResponseHeaders copied from a successful auth response to the upstream request
+	ResponseHeaders []string
+	Timeout         time.Duration
+}
+
+// RewriteOptions represents rewriting of the request path before proxying
+type RewriteOptions struct {
+	// StripPrefix removes the matched route path
+	StripPrefix bool
+	// Prefix replaces the matched route path
+	Prefix string
+	// Regex rewrites matching paths to Target, which may reference capture groups
+	Regex  *regexp.Regexp
+	Target string
+}
+
+// HTTPSOptions overrides global HTTPS redirect and HSTS settings, nil and zero values use the global setting
+type HTTPSOptions struct {
+	Redirect              *bool
+	RedirectStatus        int
+	RedirectPort          string
+	HSTSMaxAge            *int
+	HSTSIncludeSubDomains *bool
+	HSTSPreload           *bool
+}
+
+// CanaryOptions represents traffic splitting to a canary ingress
+type CanaryOptions struct {
+	// Weight percentage of requests sent to canary
+	Weight int
+	// Header forces canary when always or HeaderValue, never forces primary
+	Header      string
+	HeaderValue string
+	// Cookie forces canary when always, never forces primary
+	Cookie string
+}
+
+// AffinityOptions represents cookie based session affinity to an upstream endpoint
+type AffinityOptions struct {
+	CookieName string
+	// CookieTTL zero issues a session cookie
+	CookieTTL  time.Duration
+	CookiePath string
+}
+
+// CORSOptions represents cross origin resource sharing answered at the edge
+type CORSOptions struct {
+	// AllowOrigins may contain * or wildcard subdomains such as https://*.example.com
+	AllowOrigins     []string
+	AllowMethods     []string
+	AllowHeaders     []string
+	ExposeHeaders    []string
+	AllowCredentials bool
+	MaxAge           time.Duration
 }
 
 // TLSCertificate represents a certificate
@@ -22,8 +190,31 @@ type TLSCertificate struct {
 	SecretName  string
 }
 
+// TLSSecret represents a TLS secret referenced by an ingress
+type TLSSecret struct {
+	Namespace  string
+	SecretName string
+	Hosts      []string
+	// ACME opts in to issuing certificates even when the secret exists
+	ACME bool
+}
+
 // Service represents a service endpoint
 type Service struct {
 	ServiceName string
 	ClusterIP   string
+	Ports       []ServicePort
+}
+
+// ServicePort represents a named port exposed by a service
+type ServicePort struct {
+	Name string
+	Port int32
+}
+
+// Endpoints represents ready pod addresses backing a service
+type Endpoints struct {
+	ServiceName string
+	// Targets key is port name, value is list of ready ip:port addresses
+	Targets map[string][]string
 }
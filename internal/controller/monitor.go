@@ -3,11 +3,18 @@ package controller
 import (
 	"context"
 	"crypto/tls"
+	"fmt"
+	"regexp"
+	"sort"
 
 	certificateResource "github.com/elijahglover/inbound/internal/controller/certificates"
+	configMapsResource "github.com/elijahglover/inbound/internal/controller/configmaps"
+	endpointsResource "github.com/elijahglover/inbound/internal/controller/endpoints"
+	htpasswdResource "github.com/elijahglover/inbound/internal/controller/htpasswd"
 	ingressResource "github.com/elijahglover/inbound/internal/controller/ingress"
 	namespacesResource "github.com/elijahglover/inbound/internal/controller/namespaces"
 	servicesResource "github.com/elijahglover/inbound/internal/controller/services"
+	"github.com/elijahglover/inbound/internal/errorpages"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/api/extensions/v1beta1"
 )
@@ -121,6 +128,27 @@ func (c *Controller) monitorService(ctx context.Context, namespaceName string, s
 	}
 }
 
+func (c *Controller) monitorEndpoints(ctx context.Context, namespaceName string, serviceName string) {
+	endpointsChanged := make(chan *v1.Endpoints)
+	endpointsDeleted := make(chan string)
+
+	watcher := endpointsResource.New(c.logger, c.client, namespaceName, serviceName)
+	watcher.SubscribeEndpointsChanged(subscriberSource, endpointsChanged)
+	watcher.SubscribeEndpointsDeleted(subscriberSource, endpointsDeleted)
+	go watcher.Watch(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case endpoints := <-endpointsChanged:
+			c.endpointsChanged(endpoints)
+		case serviceName := <-endpointsDeleted:
+			c.endpointsDeleted(namespaceName, serviceName)
+		}
+	}
+}
+
 func (c *Controller) monitorCertificate(ctx context.Context, namespaceName string, secretName string) {
 	certificateChanged := make(chan *tls.Certificate)
 	certificateDelete := make(chan bool)
@@ -142,54 +170,179 @@ func (c *Controller) monitorCertificate(ctx context.Context, namespaceName strin
 	}
 }
 
+func (c *Controller) monitorCredentials(ctx context.Context, namespaceName string, secretName string) {
+	credentialsChanged := make(chan map[string]string)
+	credentialsDelete := make(chan bool)
+
+	watcher := htpasswdResource.New(c.logger, c.client, namespaceName, secretName)
+	watcher.SubscribeCredentialsChanged(subscriberSource, credentialsChanged)
+	watcher.SubscribeCredentialsDeleted(subscriberSource, credentialsDelete)
+	go watcher.Watch(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case credentials := <-credentialsChanged:
+			c.credentialsChanged(namespaceName, secretName, credentials)
+		case <-credentialsDelete:
+			c.credentialsDeleted(namespaceName, secretName)
+		}
+	}
+}
+
+func (c *Controller) monitorConfigMap(ctx context.Context, namespaceName string, configMapName string) {
+	configMapChanged := make(chan map[string]string)
+	configMapDelete := make(chan bool)
+
+	watcher := configMapsResource.New(c.logger, c.client, namespaceName, configMapName)
+	watcher.SubscribeConfigMapChanged(subscriberSource, configMapChanged)
+	watcher.SubscribeConfigMapDeleted(subscriberSource, configMapDelete)
+	go watcher.Watch(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case data := <-configMapChanged:
+			c.errorPagesChanged(namespaceName, configMapName, data)
+		case <-configMapDelete:
+			c.errorPagesDeleted(namespaceName, configMapName)
+		}
+	}
+}
+
 func (c *Controller) ingressChanged(ctx context.Context, ingress *v1beta1.Ingress) {
 	ingressKey := namespaceFormat(ingress.Namespace, ingress.Name)
+	options := c.parseIngressOptions(ingress)
 
 	c.certificatesLock.Lock()
+	c.tlsSecretsLock.Lock()
+	// Secrets no longer listed in the ingress stop being issued
+	secrets := map[string]*TLSSecret{}
+	c.tlsSecrets[ingressKey] = secrets
 	for _, tls := range ingress.Spec.TLS {
 		//Put a placeholder in certificate for certificate to attach
 		for _, host := range tls.Hosts {
 			c.certificatesSecretMap[host] = namespaceFormat(ingress.Namespace, tls.SecretName)
 		}
 
+		//Track referenced secrets so certificates can be issued
+		secrets[namespaceFormat(ingress.Namespace, tls.SecretName)] = &TLSSecret{
+			Namespace:  ingress.Namespace,
+			SecretName: tls.SecretName,
+			Hosts:      tls.Hosts,
+			ACME:       options.ACME,
+		}
+
 		//Setup watchers for certificate changes
 		go c.monitorCertificate(ctx, ingress.Namespace, tls.SecretName)
 	}
+	c.tlsSecretsLock.Unlock()
 	c.certificatesLock.Unlock()
 
-	//Setup watchers for service changes
+	//Setup watcher for basic auth credential changes
+	if options.BasicAuth != nil {
+		go c.monitorCredentials(ctx, ingress.Namespace, ingress.Annotations[annotationAuthSecret])
+	}
+
+	//Setup watchers for service and endpoint changes
 	for _, rule := range ingress.Spec.Rules {
 		for _, path := range rule.HTTP.Paths {
 			go c.monitorService(ctx, ingress.Namespace, path.Backend.ServiceName)
+			go c.monitorEndpoints(ctx, ingress.Namespace, path.Backend.ServiceName)
 		}
 	}
 
+	//Default backend receives paths not matched by rules
+	var defaultRoute *RoutePath
+	if backend := ingress.Spec.Backend; backend != nil {
+		defaultRoute = &RoutePath{
+			Ingress:     ingressKey,
+			Path:        "/",
+			ServiceName: namespaceFormat(ingress.Namespace, backend.ServiceName),
+			ServicePort: backend.ServicePort.IntVal,
+			Match:       MatchPrefix,
+			Options:     options,
+		}
+		go c.monitorService(ctx, ingress.Namespace, backend.ServiceName)
+		go c.monitorEndpoints(ctx, ingress.Namespace, backend.ServiceName)
+	}
+
+	//Ingress without rules catches traffic for every host
+	c.defaultRoutesLock.Lock()
+	if defaultRoute != nil && len(ingress.Spec.Rules) == 0 && options.Canary == nil {
+		c.defaultRoutes[ingressKey] = defaultRoute
+	} else {
+		delete(c.defaultRoutes, ingressKey)
+	}
+	c.defaultRoutesLock.Unlock()
+
 	// Lock route table
 	c.routeTableLock.Lock()
 	defer c.routeTableLock.Unlock()
 
+	// Canaries are added back below for paths still in the ingress
+	c.canariesDeleted(ingressKey)
+
 	// Process rules in ingress
 	for _, rule := range ingress.Spec.Rules {
 		// Check if route table exists for hostname
 		if _, ok := c.routeTable[rule.Host]; !ok {
 			c.routeTable[rule.Host] = &RouteTable{
-				Ingress: ingress.Name,
-				Host:    rule.Host,
-				Paths:   make([]RoutePath, 0),
+				Ingress:  ingress.Name,
+				Host:     rule.Host,
+				Paths:    make([]RoutePath, 0),
+				Canaries: map[string][]RouteCanary{},
 			}
 		}
 
 		ruleRouteTable := c.routeTable[rule.Host]
 
+		// Canary ingresses split traffic of existing paths instead of replacing them
+		if options.Canary != nil {
+			for _, path := range rule.HTTP.Paths {
+				c.canaryChanged(ruleRouteTable, path.Path, RouteCanary{
+					Ingress:     ingressKey,
+					ServiceName: namespaceFormat(ingress.Namespace, path.Backend.ServiceName),
+					ServicePort: path.Backend.ServicePort.IntVal,
+					Options:     options.Canary,
+				})
+			}
+			continue
+		}
+
+		if defaultRoute != nil {
+			ruleRouteTable.Default = defaultRoute
+		} else if ruleRouteTable.Default != nil && ruleRouteTable.Default.Ingress == ingressKey {
+			ruleRouteTable.Default = nil
+		}
+
 		for _, path := range rule.HTTP.Paths {
 			serviceKey := namespaceFormat(ingress.Namespace, path.Backend.ServiceName)
+			pathOptions := options.ForPath(path.Path)
+			var regex *regexp.Regexp
+			if pathOptions.MatchType == MatchRegex {
+				compiled, err := compileRouteRegex(path.Path)
+				if err != nil {
+					c.logger.Warningf("Ingress %s has invalid regex path %s, %s", ingressKey, path.Path, err)
+					continue
+				}
+				regex = compiled
+			}
+
 			matchedPath := matchRoutePath(ruleRouteTable.Paths, path.Path)
 			if matchedPath == nil {
 				// Create new route path
 				routePath := RoutePath{
+					Ingress:     ingressKey,
 					Path:        path.Path,
 					ServiceName: serviceKey,
 					ServicePort: path.Backend.ServicePort.IntVal,
+					Match:       pathOptions.MatchType,
+					Regex:       regex,
+					Options:     pathOptions,
+					Canaries:    ruleRouteTable.Canaries[path.Path],
 				}
 				ruleRouteTable.Paths = append(ruleRouteTable.Paths, routePath)
 				c.logger.Verbosef("Ingress route added %s %s for host %s routes to %s:%v",
@@ -203,9 +356,14 @@ func (c *Controller) ingressChanged(ctx context.Context, ingress *v1beta1.Ingres
 			}
 
 			// Update existing route path
+			matchedPath.Ingress = ingressKey
 			matchedPath.Path = path.Path
 			matchedPath.ServiceName = serviceKey
 			matchedPath.ServicePort = path.Backend.ServicePort.IntVal
+			matchedPath.Match = pathOptions.MatchType
+			matchedPath.Regex = regex
+			matchedPath.Options = pathOptions
+			matchedPath.Canaries = ruleRouteTable.Canaries[path.Path]
 			c.logger.Verbosef("Ingress route updated %s %s for host %s routes to %s:%v",
 				ingressKey,
 				path.Path,
@@ -215,22 +373,37 @@ func (c *Controller) ingressChanged(ctx context.Context, ingress *v1beta1.Ingres
 			)
 		}
 
-		// Ensure paths are sorted most complex to least complex by length
-		sortRulePathsLength(ruleRouteTable.Paths)
+		// Ensure paths are sorted most specific to least specific
+		sortRulePaths(ruleRouteTable.Paths)
 	}
 }
 
 func (c *Controller) ingressDeleted(ingress *v1beta1.Ingress) {
+	ingressKey := namespaceFormat(ingress.Namespace, ingress.Name)
+
+	c.defaultRoutesLock.Lock()
+	delete(c.defaultRoutes, ingressKey)
+	c.defaultRoutesLock.Unlock()
+
+	c.tlsSecretsLock.Lock()
+	delete(c.tlsSecrets, ingressKey)
+	c.tlsSecretsLock.Unlock()
+
 	// Lock route table
 	c.routeTableLock.Lock()
 	defer c.routeTableLock.Unlock()
 
+	c.canariesDeleted(ingressKey)
+
 	// Process rules in ingress
 	for _, rule := range ingress.Spec.Rules {
 		// Check if route table exists for hostname
 		if ruleRouteTable, ok := c.routeTable[rule.Host]; ok {
+			if ruleRouteTable.Default != nil && ruleRouteTable.Default.Ingress == ingressKey {
+				ruleRouteTable.Default = nil
+			}
 			for _, path := range rule.HTTP.Paths {
-				ruleRouteTable.Paths = deleteRoutePath(ruleRouteTable.Paths, path.Path)
+				ruleRouteTable.Paths = deleteRoutePath(ruleRouteTable.Paths, path.Path, ingressKey)
 			}
 		}
 	}
@@ -240,14 +413,72 @@ func (c *Controller) ingressDeleted(ingress *v1beta1.Ingress) {
 	// Happy path is to keep everything around for the moment
 }
 
+// canaryChanged replaces the canary of an ingress for path, route table must be locked
+func (c *Controller) canaryChanged(routeTable *RouteTable, path string, canary RouteCanary) {
+	canaries := []RouteCanary{}
+	for _, existing := range routeTable.Canaries[path] {
+		if existing.Ingress != canary.Ingress {
+			canaries = append(canaries, existing)
+		}
+	}
+	canaries = append(canaries, canary)
+	routeTable.Canaries[path] = canaries
+
+	if matchedPath := matchRoutePath(routeTable.Paths, path); matchedPath != nil {
+		matchedPath.Canaries = canaries
+	}
+	c.logger.Verbosef("Ingress canary %s %s for host %s routes %v%% to %s:%v",
+		canary.Ingress,
+		path,
+		routeTable.Host,
+		canary.Options.Weight,
+		canary.ServiceName,
+		canary.ServicePort,
+	)
+}
+
+// canariesDeleted removes every canary of an ingress across all hosts and paths, route table must be locked
+func (c *Controller) canariesDeleted(ingressKey string) {
+	for _, routeTable := range c.routeTable {
+		for path, existing := range routeTable.Canaries {
+			var canaries []RouteCanary
+			for _, canary := range existing {
+				if canary.Ingress != ingressKey {
+					canaries = append(canaries, canary)
+				}
+			}
+			if len(canaries) == len(existing) {
+				continue
+			}
+			if len(canaries) == 0 {
+				delete(routeTable.Canaries, path)
+			} else {
+				routeTable.Canaries[path] = canaries
+			}
+
+			if matchedPath := matchRoutePath(routeTable.Paths, path); matchedPath != nil {
+				matchedPath.Canaries = canaries
+			}
+		}
+	}
+}
+
 func (c *Controller) serviceChanged(service *v1.Service) {
 	c.servicesLock.Lock()
 	defer c.servicesLock.Unlock()
 
 	key := namespaceFormat(service.Namespace, service.Name)
+	ports := make([]ServicePort, len(service.Spec.Ports))
+	for i, port := range service.Spec.Ports {
+		ports[i] = ServicePort{
+			Name: port.Name,
+			Port: port.Port,
+		}
+	}
 	c.services[key] = &Service{
 		ServiceName: key,
 		ClusterIP:   service.Spec.ClusterIP,
+		Ports:       ports,
 	}
 	c.logger.Verbosef("Discovered service %s with cluster ip %s", key, service.Spec.ClusterIP)
 }
@@ -264,6 +495,45 @@ func (c *Controller) serviceDeleted(namespace string, serviceName string) {
 	c.logger.Verbosef("Removed service %s", key)
 }
 
+func (c *Controller) endpointsChanged(endpoints *v1.Endpoints) {
+	c.endpointsLock.Lock()
+	defer c.endpointsLock.Unlock()
+
+	key := namespaceFormat(endpoints.Namespace, endpoints.Name)
+	targets := map[string][]string{}
+	for _, subset := range endpoints.Subsets {
+		for _, port := range subset.Ports {
+			// Only ready addresses are routable, not ready addresses are ignored
+			for _, address := range subset.Addresses {
+				targets[port.Name] = append(targets[port.Name], fmt.Sprintf("%s:%v", address.IP, port.Port))
+			}
+		}
+	}
+
+	// Stable ordering keeps balancing consistent between updates
+	for _, portTargets := range targets {
+		sort.Strings(portTargets)
+	}
+
+	c.endpoints[key] = &Endpoints{
+		ServiceName: key,
+		Targets:     targets,
+	}
+	c.logger.Verbosef("Discovered endpoints %s with %v ports", key, len(targets))
+}
+
+func (c *Controller) endpointsDeleted(namespace string, serviceName string) {
+	key := namespaceFormat(namespace, serviceName)
+
+	c.endpointsLock.Lock()
+	defer c.endpointsLock.Unlock()
+
+	if _, ok := c.endpoints[key]; ok {
+		delete(c.endpoints, key)
+	}
+	c.logger.Verbosef("Removed endpoints %s", key)
+}
+
 func (c *Controller) certificateChanged(namespace string, secretName string, tls *tls.Certificate) {
 	key := namespaceFormat(namespace, secretName)
 
@@ -287,3 +557,49 @@ func (c *Controller) certificateDeleted(namespace string, secretName string) {
 
 	c.logger.Verbosef("Removed certificate %s", key)
 }
+
+func (c *Controller) credentialsChanged(namespace string, secretName string, credentials map[string]string) {
+	key := namespaceFormat(namespace, secretName)
+
+	c.credentialsLock.Lock()
+	defer c.credentialsLock.Unlock()
+
+	c.credentials[key] = credentials
+
+	c.logger.Verbosef("Discovered credentials %s", key)
+}
+
+func (c *Controller) credentialsDeleted(namespace string, secretName string) {
+	key := namespaceFormat(namespace, secretName)
+
+	c.credentialsLock.Lock()
+	defer c.credentialsLock.Unlock()
+
+	delete(c.credentials, key)
+
+	c.logger.Verbosef("Removed credentials %s", key)
+}
+
+func (c *Controller) errorPagesChanged(namespace string, configMapName string, data map[string]string) {
+	key := namespaceFormat(namespace, configMapName)
+	pages, errs := errorpages.Parse(data)
+	for _, err := range errs {
+		c.logger.Warningf("Ignoring error page in %s, %s", key, err)
+	}
+
+	c.errorPagesLock.Lock()
+	defer c.errorPagesLock.Unlock()
+
+	c.errorPages = pages
+
+	c.logger.Verbosef("Discovered error pages %s", key)
+}
+
+func (c *Controller) errorPagesDeleted(namespace string, configMapName string) {
+	c.errorPagesLock.Lock()
+	defer c.errorPagesLock.Unlock()
+
+	c.errorPages = nil
+
+	c.logger.Verbosef("Removed error pages %s", namespaceFormat(namespace, configMapName))
+}
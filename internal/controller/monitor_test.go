@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/elijahglover/inbound/internal/logger"
+	"k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_ingressDeleted_Removes_TLS_Secrets(t *testing.T) {
+	c := New(logger.NewNull(), "", nil)
+	c.tlsSecrets["default/a"] = map[string]*TLSSecret{
+		"default/a-tls":      {Namespace: "default", SecretName: "a-tls", Hosts: []string{"a.example.com"}},
+		"default/shared-tls": {Namespace: "default", SecretName: "shared-tls", Hosts: []string{"a.example.com"}, ACME: true},
+	}
+	c.tlsSecrets["default/b"] = map[string]*TLSSecret{
+		"default/b-tls":      {Namespace: "default", SecretName: "b-tls", Hosts: []string{"b.example.com"}},
+		"default/shared-tls": {Namespace: "default", SecretName: "shared-tls", Hosts: []string{"b.example.com"}},
+	}
+
+	secrets := c.GetTLSSecrets()
+	if len(secrets) != 3 {
+		t.Fatalf("unexpected secrets %v", secrets)
+	}
+	for _, secret := range secrets {
+		if secret.SecretName == "shared-tls" && (len(secret.Hosts) != 2 || !secret.ACME) {
+			t.Fatalf("shared secret not merged %v", secret)
+		}
+	}
+
+	// Shared secret is still issued for the remaining ingress
+	c.ingressDeleted(&v1beta1.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "a"}})
+
+	secrets = c.GetTLSSecrets()
+	if len(secrets) != 2 {
+		t.Fatalf("unexpected secrets after delete %v", secrets)
+	}
+	for _, secret := range secrets {
+		switch secret.SecretName {
+		case "b-tls":
+		case "shared-tls":
+			if len(secret.Hosts) != 1 || secret.Hosts[0] != "b.example.com" || secret.ACME {
+				t.Fatalf("unexpected shared secret after delete %v", secret)
+			}
+		default:
+			t.Fatalf("unexpected secret after delete %s", secret.SecretName)
+		}
+	}
+}
+
+func Test_ingressDeleted_Removes_Canaries(t *testing.T) {
+	c := New(logger.NewNull(), "", nil)
+	stable := RouteCanary{Ingress: "default/stable-canary", ServiceName: "default/stable-v2"}
+	canary := RouteCanary{Ingress: "default/canary", ServiceName: "default/api-v2"}
+	c.routeTable["example.com"] = &RouteTable{
+		Host:  "example.com",
+		Paths: []RoutePath{{Path: "/", Canaries: []RouteCanary{stable, canary}}, {Path: "/old", Canaries: []RouteCanary{canary}}},
+		Canaries: map[string][]RouteCanary{
+			"/":    {stable, canary},
+			"/old": {canary},
+		},
+	}
+
+	// Ingress no longer lists /old, every canary it owns is still removed
+	c.ingressDeleted(&v1beta1.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "canary"}})
+
+	routeTable := c.GetRouteTable("example.com")
+	if len(routeTable.Canaries) != 1 || len(routeTable.Canaries["/"]) != 1 || routeTable.Canaries["/"][0] != stable {
+		t.Fatalf("unexpected canaries after delete %v", routeTable.Canaries)
+	}
+	if len(routeTable.Paths[0].Canaries) != 1 || len(routeTable.Paths[1].Canaries) != 0 {
+		t.Fatalf("unexpected path canaries after delete %v %v", routeTable.Paths[0].Canaries, routeTable.Paths[1].Canaries)
+	}
+}
@@ -2,6 +2,9 @@ package controller
 
 import (
 	"crypto/tls"
+
+	"github.com/elijahglover/inbound/internal/errorpages"
+	"github.com/elijahglover/inbound/internal/helpers"
 )
 
 // GetCertificate for hostname
@@ -11,7 +14,11 @@ func (c *Controller) GetCertificate(hostname string) *tls.Certificate {
 	defer c.certificatesLock.Unlock()
 	defer c.certificatesSecretMapLock.Unlock()
 
+	// Exact hostname takes priority over wildcard
 	secretName, secretOk := c.certificatesSecretMap[hostname]
+	if !secretOk {
+		secretName, secretOk = c.certificatesSecretMap[wildcardHost(hostname)]
+	}
 	if !secretOk {
 		return nil // No certificate bound to hostname
 	}
@@ -21,6 +28,63 @@ func (c *Controller) GetCertificate(hostname string) *tls.Certificate {
 	return nil
 }
 
+// GetSecretCertificate returns the loaded certificate for a secret
+func (c *Controller) GetSecretCertificate(namespace string, secretName string) *tls.Certificate {
+	c.certificatesLock.Lock()
+	defer c.certificatesLock.Unlock()
+
+	if cert, ok := c.certificates[namespaceFormat(namespace, secretName)]; ok {
+		return cert
+	}
+	return nil
+}
+
+// GetTLSSecrets returns all TLS secrets referenced by ingresses
+func (c *Controller) GetTLSSecrets() []*TLSSecret {
+	c.tlsSecretsLock.Lock()
+	defer c.tlsSecretsLock.Unlock()
+
+	// Secrets shared by ingresses are merged, issued while any ingress references them
+	merged := map[string]*TLSSecret{}
+	wrappedArray := []*TLSSecret{}
+	for _, secrets := range c.tlsSecrets {
+		for key, secret := range secrets {
+			shared, ok := merged[key]
+			if !ok {
+				shared = &TLSSecret{Namespace: secret.Namespace, SecretName: secret.SecretName}
+				merged[key] = shared
+				wrappedArray = append(wrappedArray, shared)
+			}
+			for _, host := range secret.Hosts {
+				if !helpers.ContainsString(shared.Hosts, host) {
+					shared.Hosts = append(shared.Hosts, host)
+				}
+			}
+			shared.ACME = shared.ACME || secret.ACME
+		}
+	}
+	return wrappedArray
+}
+
+// GetCredentials returns htpasswd user to hash map for a secret, nil when not loaded
+func (c *Controller) GetCredentials(secret string) map[string]string {
+	c.credentialsLock.Lock()
+	defer c.credentialsLock.Unlock()
+
+	if credentials, ok := c.credentials[secret]; ok {
+		return credentials
+	}
+	return nil
+}
+
+// GetErrorPages returns error page templates, nil when not configured
+func (c *Controller) GetErrorPages() *errorpages.Pages {
+	c.errorPagesLock.Lock()
+	defer c.errorPagesLock.Unlock()
+
+	return c.errorPages
+}
+
 // GetService metadata
 func (c *Controller) GetService(service string) *Service {
 	c.servicesLock.Lock()
@@ -32,6 +96,48 @@ func (c *Controller) GetService(service string) *Service {
 	return nil
 }
 
+// GetEndpoints returns ready ip:port targets for a service port, nil when endpoints are unknown
+func (c *Controller) GetEndpoints(service string, port int32) []string {
+	svc := c.GetService(service)
+	if svc == nil {
+		return nil
+	}
+
+	c.endpointsLock.Lock()
+	defer c.endpointsLock.Unlock()
+
+	endpoints, ok := c.endpoints[service]
+	if !ok {
+		return nil
+	}
+
+	// Endpoint ports are matched to the service port by name
+	for _, servicePort := range svc.Ports {
+		if servicePort.Port == port {
+			targets := endpoints.Targets[servicePort.Name]
+			if targets == nil {
+				return []string{}
+			}
+			return targets
+		}
+	}
+	return []string{}
+}
+
+// GetAllEndpoints returns all known endpoints
+func (c *Controller) GetAllEndpoints() []*Endpoints {
+	c.endpointsLock.Lock()
+	defer c.endpointsLock.Unlock()
+
+	wrappedArray := make([]*Endpoints, len(c.endpoints))
+	i := 0
+	for _, endpoints := range c.endpoints {
+		wrappedArray[i] = endpoints
+		i++
+	}
+	return wrappedArray
+}
+
 // GetRouteTable to resolve traffic to service
 func (c *Controller) GetRouteTable(host string) *RouteTable {
 	c.routeTableLock.Lock()
@@ -40,9 +146,31 @@ func (c *Controller) GetRouteTable(host string) *RouteTable {
 	if route, ok := c.routeTable[host]; ok {
 		return route
 	}
+	// Fallback to wildcard rule when no exact rule exists
+	if route, ok := c.routeTable[wildcardHost(host)]; ok {
+		return route
+	}
 	return nil
 }
 
+// GetDefaultRoute returns catch all route for unmatched traffic, ingresses take priority over config
+func (c *Controller) GetDefaultRoute() *RoutePath {
+	c.defaultRoutesLock.Lock()
+	defer c.defaultRoutesLock.Unlock()
+
+	// Pick lowest ingress name so multiple catch all ingresses resolve consistently
+	var route *RoutePath
+	for key, defaultRoute := range c.defaultRoutes {
+		if route == nil || key < route.Ingress {
+			route = defaultRoute
+		}
+	}
+	if route != nil {
+		return route
+	}
+	return c.defaultBackend
+}
+
 // GetRouteTables returns all route tables
 func (c *Controller) GetRouteTables() []*RouteTable {
 	c.routeTableLock.Lock()
@@ -0,0 +1,28 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/elijahglover/inbound/internal/logger"
+)
+
+func Test_GetRouteTable_Wildcard(t *testing.T) {
+	c := New(logger.NewNull(), "", nil)
+	c.routeTable["*.example.com"] = &RouteTable{Host: "*.example.com"}
+	c.routeTable["exact.example.com"] = &RouteTable{Host: "exact.example.com"}
+
+	for input, expected := range map[string]string{
+		"a.example.com":     "*.example.com",
+		"exact.example.com": "exact.example.com",
+		"a.b.example.com":   "",
+		"example.com":       "",
+	} {
+		actual := ""
+		if route := c.GetRouteTable(input); route != nil {
+			actual = route.Host
+		}
+		if actual != expected {
+			t.Fatalf("unexpected output for %s %s %s", input, actual, expected)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package errorpages
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	htmlTemplate "html/template"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+	textTemplate "text/template"
+)
+
+const (
+	// ContentTypeHTML is rendered from <status>.html keys
+	ContentTypeHTML = "text/html; charset=utf-8"
+	// ContentTypeJSON is rendered from <status>.json keys
+	ContentTypeJSON = "application/json"
+)
+
+// Data available to templates
+type Data struct {
+	Status     int
+	StatusText string
+	Message    string
+	Host       string
+	Path       string
+}
+
+// template is satisfied by both html and text templates
+type template interface {
+	Execute(w io.Writer, data interface{}) error
+}
+
+// Pages holds error page templates keyed by status code
+type Pages struct {
+	html map[int]template
+	json map[int]template
+}
+
+// Parse templates from configmap data, keys are <status>.html or <status>.json
+// Invalid entries are returned as errors and skipped
+func Parse(data map[string]string) (*Pages, []error) {
+	pages := &Pages{
+		html: map[int]template{},
+		json: map[int]template{},
+	}
+	errs := []error{}
+
+	for key, value := range data {
+		dot := strings.LastIndex(key, ".")
+		if dot < 0 {
+			errs = append(errs, fmt.Errorf("Unknown error page key %s", key))
+			continue
+		}
+		status, err := strconv.Atoi(key[:dot])
+		if err != nil || status < 400 || status > 599 {
+			errs = append(errs, fmt.Errorf("Unknown error page key %s", key))
+			continue
+		}
+
+		switch key[dot+1:] {
+		case "html":
+			parsed, err := htmlTemplate.New(key).Parse(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("Invalid error page %s %s", key, err))
+				continue
+			}
+			pages.html[status] = parsed
+		case "json":
+			// json function quotes values so templates produce valid documents
+			parsed, err := textTemplate.New(key).Funcs(textTemplate.FuncMap{"json": jsonValue}).Parse(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("Invalid error page %s %s", key, err))
+				continue
+			}
+			pages.json[status] = parsed
+		default:
+			errs = append(errs, fmt.Errorf("Unknown error page key %s", key))
+		}
+	}
+	return pages, errs
+}
+
+// Render error page for status in the format preferred by accept header
+func (p *Pages) Render(accept string, data Data) (string, []byte, bool) {
+	htmlPage, htmlOk := p.html[data.Status]
+	jsonPage, jsonOk := p.json[data.Status]
+
+	var page template
+	contentType := ""
+	htmlQuality, jsonQuality := acceptQuality(accept, "text", "html"), acceptQuality(accept, "application", "json")
+	switch {
+	case htmlOk && (!jsonOk || htmlQuality >= jsonQuality) && htmlQuality > 0:
+		page, contentType = htmlPage, ContentTypeHTML
+	case jsonOk && jsonQuality > 0:
+		page, contentType = jsonPage, ContentTypeJSON
+	default:
+		return "", nil, false
+	}
+
+	buf := &bytes.Buffer{}
+	if err := page.Execute(buf, data); err != nil {
+		return "", nil, false
+	}
+	return contentType, buf.Bytes(), true
+}
+
+// acceptQuality returns the q value accept header gives media type, missing header accepts everything
+func acceptQuality(accept string, mediaType string, subType string) float64 {
+	if strings.TrimSpace(accept) == "" {
+		return 1
+	}
+
+	best := 0.0
+	specificity := -1
+	for _, item := range strings.Split(accept, ",") {
+		parsed, params, err := mime.ParseMediaType(strings.TrimSpace(item))
+		if err != nil {
+			continue
+		}
+
+		// Most specific matching range decides the quality
+		rangeSpecificity := 0
+		switch parsed {
+		case mediaType + "/" + subType:
+			rangeSpecificity = 2
+		case mediaType + "/*":
+			rangeSpecificity = 1
+		case "*/*":
+		default:
+			continue
+		}
+		if rangeSpecificity < specificity {
+			continue
+		}
+
+		quality := 1.0
+		if value, ok := params["q"]; ok {
+			if parsedQuality, err := strconv.ParseFloat(value, 64); err == nil {
+				quality = parsedQuality
+			}
+		}
+		if rangeSpecificity > specificity || quality > best {
+			best = quality
+		}
+		specificity = rangeSpecificity
+	}
+	return best
+}
+
+func jsonValue(value interface{}) (string, error) {
+	encoded, err := json.Marshal(value)
+	return string(encoded), err
+}
@@ -0,0 +1,45 @@
+package errorpages_test
+
+import (
+	"testing"
+
+	"github.com/elijahglover/inbound/internal/errorpages"
+)
+
+func Test_ErrorPages_Render(t *testing.T) {
+	pages, errs := errorpages.Parse(map[string]string{
+		"404.html": "<h1>{{.StatusText}}</h1><p>{{.Path}}</p>",
+		"404.json": `{"status":{{.Status}},"message":{{json .Message}}}`,
+		"503.html": "<h1>Down</h1>",
+		"teapot":   "invalid",
+	})
+	if len(errs) != 1 {
+		t.Fatalf("unexpected parse errors %v", errs)
+	}
+
+	data := errorpages.Data{Status: 404, StatusText: "Not Found", Message: `no "route"`, Path: "/<script>"}
+	cases := []struct {
+		accept      string
+		contentType string
+		body        string
+	}{
+		{"", errorpages.ContentTypeHTML, "<h1>Not Found</h1><p>/&lt;script&gt;</p>"},
+		{"text/html,application/xhtml+xml,*/*;q=0.8", errorpages.ContentTypeHTML, "<h1>Not Found</h1><p>/&lt;script&gt;</p>"},
+		{"application/json", errorpages.ContentTypeJSON, `{"status":404,"message":"no \"route\""}`},
+		{"text/html;q=0.5, application/json", errorpages.ContentTypeJSON, `{"status":404,"message":"no \"route\""}`},
+	}
+	for _, c := range cases {
+		contentType, body, ok := pages.Render(c.accept, data)
+		if !ok || contentType != c.contentType || string(body) != c.body {
+			t.Fatalf("unexpected output for %s %v %s %s", c.accept, ok, contentType, body)
+		}
+	}
+
+	// Only html exists for 503
+	if _, _, ok := pages.Render("application/json", errorpages.Data{Status: 503}); ok {
+		t.Fatalf("expected no page for json 503")
+	}
+	if _, _, ok := pages.Render("", errorpages.Data{Status: 502}); ok {
+		t.Fatalf("expected no page for 502")
+	}
+}
@@ -0,0 +1,192 @@
+package healthcheck
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/elijahglover/inbound/internal/logger"
+)
+
+const userAgent = "inbound-healthcheck"
+
+// Target represents an upstream address to probe
+type Target struct {
+	Address            string
+	Path               string
+	Interval           time.Duration
+	Timeout            time.Duration
+	UnhealthyThreshold int
+	HealthyThreshold   int
+}
+
+// Status represents the last known health of a target
+type Status struct {
+	Address   string
+	Path      string
+	Healthy   bool
+	Failures  int
+	Successes int
+	LastCheck time.Time
+	LastError string
+}
+
+type probe struct {
+	target Target
+	status *Status
+	cancel context.CancelFunc
+}
+
+// Checker actively probes upstream targets
+type Checker struct {
+	logger     logger.Logger
+	probes     map[string]*probe
+	probesLock *sync.Mutex
+}
+
+// New health checker
+func New(logger logger.Logger) *Checker {
+	return &Checker{
+		logger:     logger,
+		probes:     map[string]*probe{},
+		probesLock: &sync.Mutex{},
+	}
+}
+
+// Sync starts probing new targets and stops probing targets no longer present
+func (c *Checker) Sync(ctx context.Context, targets []Target) {
+	c.probesLock.Lock()
+	defer c.probesLock.Unlock()
+
+	desired := map[string]Target{}
+	for _, target := range targets {
+		desired[target.Address] = target
+	}
+
+	// Remove stale or reconfigured probes
+	for address, p := range c.probes {
+		if target, ok := desired[address]; ok && target == p.target {
+			continue
+		}
+		p.cancel()
+		delete(c.probes, address)
+		c.logger.Verbosef("Stopped health check for %s", address)
+	}
+
+	// Start missing probes, targets are considered healthy until proven otherwise
+	for address, target := range desired {
+		if _, ok := c.probes[address]; ok {
+			continue
+		}
+		probeCtx, cancel := context.WithCancel(ctx)
+		p := &probe{
+			target: target,
+			status: &Status{Address: address, Path: target.Path, Healthy: true},
+			cancel: cancel,
+		}
+		c.probes[address] = p
+		go c.run(probeCtx, p)
+		c.logger.Verbosef("Started health check for %s%s every %s", address, target.Path, target.Interval)
+	}
+}
+
+// Healthy returns false only when target has been checked and marked unhealthy
+func (c *Checker) Healthy(address string) bool {
+	c.probesLock.Lock()
+	defer c.probesLock.Unlock()
+
+	if p, ok := c.probes[address]; ok {
+		return p.status.Healthy
+	}
+	return true
+}
+
+// Statuses returns a snapshot of all probed targets
+func (c *Checker) Statuses() []Status {
+	c.probesLock.Lock()
+	defer c.probesLock.Unlock()
+
+	statuses := make([]Status, 0, len(c.probes))
+	for _, p := range c.probes {
+		statuses = append(statuses, *p.status)
+	}
+	return statuses
+}
+
+func (c *Checker) run(ctx context.Context, p *probe) {
+	client := &http.Client{
+		Timeout: p.target.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	ticker := time.NewTicker(p.target.Interval)
+	defer ticker.Stop()
+
+	for {
+		err := c.check(ctx, client, p.target)
+		if ctx.Err() != nil {
+			return
+		}
+		c.record(p, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Checker) check(ctx context.Context, client *http.Client, target Target) error {
+	req, err := http.NewRequest("GET", fmt.Sprintf("http://%s%s", target.Address, target.Path), nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("Unexpected status code %v", resp.StatusCode)
+	}
+	return nil
+}
+
+func (c *Checker) record(p *probe, err error) {
+	c.probesLock.Lock()
+	defer c.probesLock.Unlock()
+
+	status := p.status
+	status.LastCheck = time.Now()
+
+	if err != nil {
+		status.LastError = err.Error()
+		status.Successes = 0
+		status.Failures++
+		if status.Healthy && status.Failures >= p.target.UnhealthyThreshold {
+			status.Healthy = false
+			c.logger.Warningf("Upstream %s marked unhealthy after %v failures, %s", status.Address, status.Failures, err)
+		}
+		return
+	}
+
+	status.LastError = ""
+	status.Failures = 0
+	status.Successes++
+	if !status.Healthy && status.Successes >= p.target.HealthyThreshold {
+		status.Healthy = true
+		c.logger.Infof("Upstream %s marked healthy after %v successes", status.Address, status.Successes)
+	}
+}
@@ -0,0 +1,50 @@
+package healthcheck
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/elijahglover/inbound/internal/logger"
+)
+
+func Test_Checker_Record_Thresholds(t *testing.T) {
+	checker := New(logger.NewNull())
+	p := &probe{
+		target: Target{Address: "10.0.0.1:80", UnhealthyThreshold: 3, HealthyThreshold: 2},
+		status: &Status{Address: "10.0.0.1:80", Healthy: true},
+	}
+	checker.probes[p.target.Address] = p
+	failure := errors.New("connection refused")
+
+	// Success in between failures resets the consecutive count
+	for i, err := range []error{failure, failure, nil, failure, failure} {
+		checker.record(p, err)
+		if !checker.Healthy(p.target.Address) {
+			t.Fatalf("marked unhealthy before threshold at result %v", i)
+		}
+	}
+
+	checker.record(p, failure)
+	if checker.Healthy(p.target.Address) {
+		t.Fatalf("not marked unhealthy after %v consecutive failures", p.target.UnhealthyThreshold)
+	}
+	if p.status.LastError != failure.Error() {
+		t.Fatalf("unexpected last error %s", p.status.LastError)
+	}
+
+	// Failure in between successes resets the consecutive count
+	for i, err := range []error{nil, failure, nil} {
+		checker.record(p, err)
+		if checker.Healthy(p.target.Address) {
+			t.Fatalf("marked healthy before threshold at result %v", i)
+		}
+	}
+
+	checker.record(p, nil)
+	if !checker.Healthy(p.target.Address) {
+		t.Fatalf("not marked healthy after %v consecutive successes", p.target.HealthyThreshold)
+	}
+	if p.status.LastError != "" {
+		t.Fatalf("unexpected last error %s", p.status.LastError)
+	}
+}
@@ -0,0 +1,32 @@
+package helpers_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/elijahglover/inbound/internal/helpers"
+)
+
+func Test_CIDR_Contains(t *testing.T) {
+	networks, err := helpers.ParseCIDRs("10.0.0.0/8, 192.168.1.1, 2001:db8::/32")
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	for input, expected := range map[string]bool{
+		"10.1.2.3":        true,
+		"192.168.1.1":     true,
+		"192.168.1.2":     false,
+		"2001:db8::1":     true,
+		"2001:db9::1":     false,
+		"::ffff:10.0.0.1": true,
+	} {
+		if actual := helpers.ContainsIP(networks, net.ParseIP(input)); actual != expected {
+			t.Fatalf("unexpected output for %s %v %v", input, actual, expected)
+		}
+	}
+
+	if _, err := helpers.ParseCIDRs("10.0.0.0/33"); err == nil {
+		t.Fatalf("expected error for invalid range")
+	}
+}
@@ -10,3 +10,13 @@ func ExtractHostname(host string) string {
 	}
 	return host[0:index]
 }
+
+// ContainsString checks if value exists in slice
+func ContainsString(values []string, value string) bool {
+	for _, item := range values {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,265 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ContentType of the Prometheus text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets for latency histograms in seconds
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type metric interface {
+	write(w io.Writer)
+}
+
+// Registry holds metrics and renders them in Prometheus text format
+type Registry struct {
+	metrics     []metric
+	metricsLock *sync.Mutex
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		metrics:     make([]metric, 0),
+		metricsLock: &sync.Mutex{},
+	}
+}
+
+// NewCounter registers a counter
+func (r *Registry) NewCounter(name string, help string, labels ...string) *Counter {
+	counter := &Counter{vector: newVector(name, help, labels)}
+	r.register(counter)
+	return counter
+}
+
+// NewGauge registers a gauge
+func (r *Registry) NewGauge(name string, help string, labels ...string) *Gauge {
+	gauge := &Gauge{vector: newVector(name, help, labels)}
+	r.register(gauge)
+	return gauge
+}
+
+// NewHistogram registers a histogram with upper bound buckets in ascending order
+func (r *Registry) NewHistogram(name string, help string, buckets []float64, labels ...string) *Histogram {
+	histogram := &Histogram{
+		name:       name,
+		help:       help,
+		labels:     labels,
+		buckets:    buckets,
+		series:     map[string]*histogramSeries{},
+		seriesLock: &sync.Mutex{},
+	}
+	r.register(histogram)
+	return histogram
+}
+
+// Write renders all metrics
+func (r *Registry) Write(w io.Writer) {
+	r.metricsLock.Lock()
+	defer r.metricsLock.Unlock()
+
+	for _, m := range r.metrics {
+		m.write(w)
+	}
+}
+
+func (r *Registry) register(m metric) {
+	r.metricsLock.Lock()
+	defer r.metricsLock.Unlock()
+	r.metrics = append(r.metrics, m)
+}
+
+// vector stores a single value per label combination
+type vector struct {
+	name       string
+	help       string
+	labels     []string
+	values     map[string]float64
+	valuesLock *sync.Mutex
+}
+
+func newVector(name string, help string, labels []string) *vector {
+	return &vector{
+		name:       name,
+		help:       help,
+		labels:     labels,
+		values:     map[string]float64{},
+		valuesLock: &sync.Mutex{},
+	}
+}
+
+func (v *vector) add(value float64, labelValues []string) {
+	key := formatLabels(v.labels, labelValues)
+	v.valuesLock.Lock()
+	defer v.valuesLock.Unlock()
+	v.values[key] += value
+}
+
+func (v *vector) set(value float64, labelValues []string) {
+	key := formatLabels(v.labels, labelValues)
+	v.valuesLock.Lock()
+	defer v.valuesLock.Unlock()
+	v.values[key] = value
+}
+
+func (v *vector) writeType(w io.Writer, metricType string) {
+	v.valuesLock.Lock()
+	defer v.valuesLock.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", v.name, v.help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", v.name, metricType)
+	for _, key := range sortedKeys(v.values) {
+		fmt.Fprintf(w, "%s%s %s\n", v.name, key, formatValue(v.values[key]))
+	}
+}
+
+// Counter only increases
+type Counter struct {
+	*vector
+}
+
+// Inc increments counter by one
+func (c *Counter) Inc(labelValues ...string) {
+	c.add(1, labelValues)
+}
+
+// Add increments counter by value
+func (c *Counter) Add(value float64, labelValues ...string) {
+	c.add(value, labelValues)
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.writeType(w, "counter")
+}
+
+// Gauge can increase and decrease
+type Gauge struct {
+	*vector
+}
+
+// Inc increments gauge by one
+func (g *Gauge) Inc(labelValues ...string) {
+	g.add(1, labelValues)
+}
+
+// Dec decrements gauge by one
+func (g *Gauge) Dec(labelValues ...string) {
+	g.add(-1, labelValues)
+}
+
+// Set gauge to value
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.set(value, labelValues)
+}
+
+func (g *Gauge) write(w io.Writer) {
+	g.writeType(w, "gauge")
+}
+
+type histogramSeries struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Histogram counts observations into buckets
+type Histogram struct {
+	name       string
+	help       string
+	labels     []string
+	buckets    []float64
+	series     map[string]*histogramSeries
+	seriesLock *sync.Mutex
+}
+
+// Observe records value
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	key := formatLabels(h.labels, labelValues)
+
+	h.seriesLock.Lock()
+	defer h.seriesLock.Unlock()
+
+	series, ok := h.series[key]
+	if !ok {
+		series = &histogramSeries{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = series
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			series.counts[i]++
+		}
+	}
+	series.count++
+	series.sum += value
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.seriesLock.Lock()
+	defer h.seriesLock.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", h.name, h.help)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", h.name)
+
+	keys := make([]string, 0, len(h.series))
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		series := h.series[key]
+		for i, bound := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %v\n", h.name, withLabel(key, "le", formatValue(bound)), series.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %v\n", h.name, withLabel(key, "le", "+Inf"), series.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, key, formatValue(series.sum))
+		fmt.Fprintf(w, "%s_count%s %v\n", h.name, key, series.count)
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatLabels renders {name="value",...}, missing values are empty
+func formatLabels(names []string, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		pairs[i] = fmt.Sprintf(`%s="%s"`, name, labelEscaper.Replace(value))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// withLabel appends a label to an already formatted label set
+func withLabel(labels string, name string, value string) string {
+	pair := fmt.Sprintf(`%s="%s"`, name, value)
+	if labels == "" {
+		return "{" + pair + "}"
+	}
+	return labels[:len(labels)-1] + "," + pair + "}"
+}
+
+func formatValue(value float64) string {
+	return fmt.Sprintf("%v", value)
+}
+
+func sortedKeys(values map[string]float64) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,45 @@
+package metrics_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/elijahglover/inbound/internal/metrics"
+)
+
+func Test_Counter_Write(t *testing.T) {
+	registry := metrics.NewRegistry()
+	counter := registry.NewCounter("inbound_requests_total", "Total requests", "host", "class")
+	counter.Inc("example.com", "2xx")
+	counter.Inc("example.com", "2xx")
+
+	buf := new(bytes.Buffer)
+	registry.Write(buf)
+
+	expected := `inbound_requests_total{host="example.com",class="2xx"} 2`
+	if !strings.Contains(buf.String(), expected) {
+		t.Fatalf("unexpected output %s %s", buf.String(), expected)
+	}
+}
+
+func Test_Histogram_Write(t *testing.T) {
+	registry := metrics.NewRegistry()
+	histogram := registry.NewHistogram("inbound_request_duration_seconds", "Request duration", []float64{0.1, 1}, "host")
+	histogram.Observe(0.05, "example.com")
+	histogram.Observe(0.5, "example.com")
+
+	buf := new(bytes.Buffer)
+	registry.Write(buf)
+
+	for _, expected := range []string{
+		`inbound_request_duration_seconds_bucket{host="example.com",le="0.1"} 1`,
+		`inbound_request_duration_seconds_bucket{host="example.com",le="1"} 2`,
+		`inbound_request_duration_seconds_bucket{host="example.com",le="+Inf"} 2`,
+		`inbound_request_duration_seconds_count{host="example.com"} 2`,
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Fatalf("unexpected output %s %s", buf.String(), expected)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+const sweepInterval = time.Minute
+
+// Result of a rate limit check
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter until a token is available, zero when allowed
+	RetryAfter time.Duration
+	// Reset until the bucket is full again
+	Reset time.Duration
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	rate    float64
+	burst   int
+}
+
+// Limiter is a token bucket limiter keyed by an arbitrary string
+type Limiter struct {
+	buckets     map[string]*bucket
+	bucketsLock *sync.Mutex
+}
+
+// New limiter
+func New() *Limiter {
+	return &Limiter{
+		buckets:     map[string]*bucket{},
+		bucketsLock: &sync.Mutex{},
+	}
+}
+
+// Allow takes a token from the bucket for key, refilling at rate per second up to burst
+func (l *Limiter) Allow(key string, rate float64, burst int) Result {
+	l.bucketsLock.Lock()
+	defer l.bucketsLock.Unlock()
+
+	now := time.Now()
+	b, ok := l.buckets[key]
+	if !ok || b.rate != rate || b.burst != burst {
+		b = &bucket{tokens: float64(burst), updated: now, rate: rate, burst: burst}
+		l.buckets[key] = b
+	}
+
+	// Refill tokens since last request
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.updated).Seconds()*rate)
+	b.updated = now
+
+	result := Result{Limit: burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsDuration((1 - b.tokens) / rate)
+	}
+	result.Remaining = int(math.Floor(b.tokens))
+	result.Reset = secondsDuration((float64(burst) - b.tokens) / rate)
+	return result
+}
+
+// Run removes full buckets until context is cancelled
+func (l *Limiter) Run(ctx context.Context) {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			l.sweep()
+		}
+	}
+}
+
+func (l *Limiter) sweep() {
+	l.bucketsLock.Lock()
+	defer l.bucketsLock.Unlock()
+
+	now := time.Now()
+	for key, b := range l.buckets {
+		// A full bucket is the same as a missing bucket
+		if b.tokens+now.Sub(b.updated).Seconds()*b.rate >= float64(b.burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func secondsDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,31 @@
+package ratelimit_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elijahglover/inbound/internal/ratelimit"
+)
+
+func Test_Limiter_Burst_And_Refill(t *testing.T) {
+	limiter := ratelimit.New()
+
+	for i := 0; i < 2; i++ {
+		if !limiter.Allow("client", 50, 2).Allowed {
+			t.Fatalf("request %v rejected within burst", i)
+		}
+	}
+
+	result := limiter.Allow("client", 50, 2)
+	if result.Allowed {
+		t.Fatalf("request allowed over burst")
+	}
+	if result.RetryAfter <= 0 || result.RetryAfter > 20*time.Millisecond {
+		t.Fatalf("unexpected retry after %s", result.RetryAfter)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if !limiter.Allow("client", 50, 2).Allowed {
+		t.Fatalf("request rejected after refill")
+	}
+}
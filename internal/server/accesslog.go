@@ -0,0 +1,48 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/elijahglover/inbound/internal/accesslog"
+)
+
+func (s *Server) accessLogEntry(req *http.Request, recorder *responseRecorder, info *requestInfo) accesslog.Entry {
+	tlsVersion := ""
+	if req.TLS != nil {
+		tlsVersion = tlsVersionName(req.TLS.Version)
+	}
+
+	return accesslog.Entry{
+		Time:       info.start,
+		ClientIP:   s.clientIP(req),
+		Host:       req.Host,
+		Method:     req.Method,
+		Path:       req.RequestURI,
+		Protocol:   req.Proto,
+		Status:     info.status(recorder),
+		Bytes:      recorder.bytes,
+		Duration:   time.Since(info.start),
+		Upstream:   info.upstream,
+		Ingress:    info.ingress,
+		TLSVersion: tlsVersion,
+		Referer:    req.Referer(),
+		UserAgent:  req.UserAgent(),
+	}
+}
+
+func tlsVersionName(version uint16) string {
+	switch version {
+	case tls.VersionTLS10:
+		return "TLSv1.0"
+	case tls.VersionTLS11:
+		return "TLSv1.1"
+	case tls.VersionTLS12:
+		return "TLSv1.2"
+	case tls.VersionTLS13:
+		return "TLSv1.3"
+	}
+	return fmt.Sprintf("0x%04x", version)
+}
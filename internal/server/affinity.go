@@ -0,0 +1,78 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/elijahglover/inbound/internal/controller"
+)
+
+// newAffinityKey uses configured secret so replicas share cookies, otherwise a random key
+func newAffinityKey(secret string) []byte {
+	if secret != "" {
+		return []byte(secret)
+	}
+	key := make([]byte, 32)
+	rand.Read(key)
+	return key
+}
+
+// affinityTarget returns upstream pinned by a valid affinity cookie, empty when missing or invalid
+func (s *Server) affinityTarget(req *http.Request, route *controller.RoutePath) string {
+	options := route.Options.Affinity
+	if options == nil {
+		return ""
+	}
+	cookie, err := req.Cookie(options.CookieName)
+	if err != nil {
+		return ""
+	}
+
+	parts := strings.SplitN(cookie.Value, ".", 2)
+	if len(parts) != 2 {
+		return ""
+	}
+	target, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return ""
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(signature, s.signAffinity(route.ServiceName, string(target))) {
+		return ""
+	}
+	return string(target)
+}
+
+// setAffinityCookie pins client to upstream when not already pinned to it
+func (s *Server) setAffinityCookie(w http.ResponseWriter, req *http.Request, route *controller.RoutePath, upstream string) {
+	options := route.Options.Affinity
+	if options == nil || s.affinityTarget(req, route) == upstream {
+		return
+	}
+
+	cookie := &http.Cookie{
+		Name:     options.CookieName,
+		Value:    base64.RawURLEncoding.EncodeToString([]byte(upstream)) + "." + base64.RawURLEncoding.EncodeToString(s.signAffinity(route.ServiceName, upstream)),
+		Path:     options.CookiePath,
+		HttpOnly: true,
+		Secure:   s.isSecure(req),
+		SameSite: http.SameSiteLaxMode,
+	}
+	if options.CookieTTL > 0 {
+		cookie.Expires = time.Now().Add(options.CookieTTL)
+		cookie.MaxAge = int(options.CookieTTL.Seconds())
+	}
+	w.Header().Add("Set-Cookie", cookie.String())
+}
+
+// signAffinity binds target to service so cookies can't select arbitrary upstreams
+func (s *Server) signAffinity(service string, target string) []byte {
+	mac := hmac.New(sha256.New, s.affinityKey)
+	mac.Write([]byte(service + "|" + target))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elijahglover/inbound/internal/circuit"
+	"github.com/elijahglover/inbound/internal/config"
+	"github.com/elijahglover/inbound/internal/controller"
+	"github.com/elijahglover/inbound/internal/healthcheck"
+)
+
+func newAffinityRoute() *controller.RoutePath {
+	return &controller.RoutePath{
+		ServiceName: "default/api",
+		ServicePort: 80,
+		Options: &controller.IngressOptions{Affinity: &controller.AffinityOptions{
+			CookieName: "route",
+			CookiePath: "/",
+			CookieTTL:  time.Hour,
+		}},
+	}
+}
+
+func Test_affinityTarget_Round_Trip(t *testing.T) {
+	s := &Server{config: &config.Config{}, affinityKey: newAffinityKey("secret")}
+	route := newAffinityRoute()
+
+	recorder := httptest.NewRecorder()
+	s.setAffinityCookie(recorder, httptest.NewRequest("GET", "http://example.com/", nil), route, "10.0.0.1:80")
+	cookies := recorder.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("unexpected cookies %v", cookies)
+	}
+	cookie := cookies[0]
+	if cookie.Name != "route" || cookie.Path != "/" || !cookie.HttpOnly || cookie.MaxAge != 3600 {
+		t.Fatalf("unexpected cookie %v", cookie)
+	}
+
+	req := httptest.NewRequest("GET", "http://example.com/", nil)
+	req.AddCookie(cookie)
+	if actual := s.affinityTarget(req, route); actual != "10.0.0.1:80" {
+		t.Fatalf("unexpected output %s", actual)
+	}
+
+	// Cookies signed with the configured secret are valid across replicas
+	replica := &Server{config: &config.Config{}, affinityKey: newAffinityKey("secret")}
+	if actual := replica.affinityTarget(req, route); actual != "10.0.0.1:80" {
+		t.Fatalf("unexpected output from replica %s", actual)
+	}
+
+	// Already pinned clients aren't sent the cookie again
+	recorder = httptest.NewRecorder()
+	s.setAffinityCookie(recorder, req, route, "10.0.0.1:80")
+	if len(recorder.Result().Cookies()) != 0 {
+		t.Fatalf("unexpected cookie for pinned client")
+	}
+
+	// Clients are re-pinned when served by another upstream
+	recorder = httptest.NewRecorder()
+	s.setAffinityCookie(recorder, req, route, "10.0.0.2:80")
+	cookies = recorder.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("unexpected cookies after re-balancing %v", cookies)
+	}
+	req = httptest.NewRequest("GET", "http://example.com/", nil)
+	req.AddCookie(cookies[0])
+	if actual := s.affinityTarget(req, route); actual != "10.0.0.2:80" {
+		t.Fatalf("unexpected output after re-balancing %s", actual)
+	}
+}
+
+func Test_affinityTarget_Invalid(t *testing.T) {
+	s := &Server{config: &config.Config{}, affinityKey: newAffinityKey("secret")}
+	foreign := &Server{config: &config.Config{}, affinityKey: newAffinityKey("other")}
+	route := newAffinityRoute()
+	otherRoute := newAffinityRoute()
+	otherRoute.ServiceName = "default/admin"
+
+	signed := func(server *Server, route *controller.RoutePath, upstream string) string {
+		recorder := httptest.NewRecorder()
+		server.setAffinityCookie(recorder, httptest.NewRequest("GET", "http://example.com/", nil), route, upstream)
+		return recorder.Result().Cookies()[0].Value
+	}
+	valid := signed(s, route, "10.0.0.1:80")
+	signature := valid[strings.Index(valid, ".")+1:]
+
+	cases := []struct {
+		name  string
+		value string
+	}{
+		{"tampered target", base64.RawURLEncoding.EncodeToString([]byte("10.0.0.9:80")) + "." + signature},
+		{"tampered signature", valid[:len(valid)-2] + "AA"},
+		{"foreign key", signed(foreign, route, "10.0.0.1:80")},
+		{"other service", signed(s, otherRoute, "10.0.0.1:80")},
+		{"unsigned", base64.RawURLEncoding.EncodeToString([]byte("10.0.0.1:80"))},
+		{"not encoded", "10.0.0.1:80." + signature},
+		{"empty", ""},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		req.AddCookie(&http.Cookie{Name: "route", Value: c.value})
+		if actual := s.affinityTarget(req, route); actual != "" {
+			t.Fatalf("unexpected output for %s %s", c.name, actual)
+		}
+	}
+}
+
+func Test_resolveUpstream_Affinity(t *testing.T) {
+	// Health checks fail against this upstream
+	unhealthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(500)
+	}))
+	defer unhealthy.Close()
+	unhealthyHost := strings.TrimPrefix(unhealthy.URL, "http://")
+
+	s := newTestServer(&config.Config{}, &fakeController{
+		services:  map[string]*controller.Service{"default/api": {ServiceName: "default/api", ClusterIP: "10.0.1.1"}},
+		endpoints: map[string][]string{"default/api": {"10.0.0.1:80", "10.0.0.2:80", "10.0.0.3:80", unhealthyHost}},
+	})
+	route := newAffinityRoute()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.healthChecker.Sync(ctx, []healthcheck.Target{{Address: unhealthyHost, Path: "/", Interval: time.Hour, Timeout: time.Second, UnhealthyThreshold: 1, HealthyThreshold: 1}})
+	for deadline := time.Now().Add(time.Second); s.healthChecker.Healthy(unhealthyHost); {
+		if time.Now().After(deadline) {
+			t.Fatalf("upstream not marked unhealthy")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	s.breaker.Failure("10.0.0.2:80", circuit.Settings{Threshold: 1, Cooldown: time.Hour}, false)
+
+	cases := []struct {
+		name     string
+		pinned   string
+		exclude  []string
+		expected string
+	}{
+		{"pinned", "10.0.0.1:80", nil, "10.0.0.1:80"},
+		{"pinned excluded", "10.0.0.1:80", []string{"10.0.0.1:80"}, ""},
+		{"pinned circuit open", "10.0.0.2:80", nil, ""},
+		{"pinned unhealthy", unhealthyHost, nil, ""},
+		{"pinned unknown", "10.0.0.9:80", nil, ""},
+	}
+	for _, c := range cases {
+		actual, err := s.resolveUpstream(route, c.exclude, c.pinned)
+		if err != nil {
+			t.Fatalf("unexpected error for %s %s", c.name, err)
+		}
+		if c.expected != "" && actual != c.expected {
+			t.Fatalf("unexpected output for %s %s %s", c.name, actual, c.expected)
+		}
+		// Unavailable pins are re-balanced across the remaining upstreams
+		if c.expected == "" && (actual == c.pinned || actual == unhealthyHost || actual == "10.0.0.2:80") {
+			t.Fatalf("unexpected output for %s %s", c.name, actual)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/elijahglover/inbound/internal/controller"
+	"github.com/elijahglover/inbound/internal/controller/htpasswd"
+)
+
+// allowBasicAuth checks credentials against the route secret, responds with 401 and returns false when rejected
+func (s *Server) allowBasicAuth(w http.ResponseWriter, req *http.Request, route *controller.RoutePath) bool {
+	options := route.Options.BasicAuth
+	if options == nil {
+		return true
+	}
+
+	// Missing secret denies everyone rather than exposing the route
+	credentials := s.controller.GetCredentials(options.Secret)
+	if credentials == nil {
+		s.logger.Infof("Credentials %s not loaded for %s %s", options.Secret, route.Ingress, route.Path)
+	}
+
+	user, password, ok := req.BasicAuth()
+	if ok {
+		if hash, found := credentials[user]; found && htpasswd.Verify(hash, password) {
+			return true
+		}
+		s.logger.Verbosef("Invalid credentials for user %s on %s %s", user, route.Ingress, route.Path)
+	}
+
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q, charset=\"UTF-8\"", options.Realm))
+	s.writeError(w, req, 401, "Unauthorized")
+	return false
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"math/rand"
+	"net/http"
+
+	"github.com/elijahglover/inbound/internal/controller"
+)
+
+// selectCanary returns the route with a canary service when request is sent to a canary
+func (s *Server) selectCanary(req *http.Request, route *controller.RoutePath) *controller.RoutePath {
+	if len(route.Canaries) == 0 {
+		return route
+	}
+
+	// Header and cookie forcing take priority over weights
+	for _, canary := range route.Canaries {
+		if selected, forced := canaryForced(req, canary.Options); forced {
+			if selected {
+				return canaryRoute(route, canary)
+			}
+			return route
+		}
+	}
+
+	roll := rand.Intn(100)
+	for _, canary := range route.Canaries {
+		if roll < canary.Options.Weight {
+			return canaryRoute(route, canary)
+		}
+		roll -= canary.Options.Weight
+	}
+	return route
+}
+
+// canaryForced checks header then cookie, returns if canary is selected and if selection was forced
+func canaryForced(req *http.Request, options *controller.CanaryOptions) (bool, bool) {
+	if options.Header != "" {
+		value := req.Header.Get(options.Header)
+		if options.HeaderValue != "" {
+			if value == options.HeaderValue {
+				return true, true
+			}
+		} else if value == "always" || value == "never" {
+			return value == "always", true
+		}
+	}
+	if options.Cookie != "" {
+		if cookie, err := req.Cookie(options.Cookie); err == nil && (cookie.Value == "always" || cookie.Value == "never") {
+			return cookie.Value == "always", true
+		}
+	}
+	return false, false
+}
+
+// canaryRoute copies route targeting canary service, route policies are kept from the primary
+func canaryRoute(route *controller.RoutePath, canary controller.RouteCanary) *controller.RoutePath {
+	selected := *route
+	selected.Ingress = canary.Ingress
+	selected.ServiceName = canary.ServiceName
+	selected.ServicePort = canary.ServicePort
+	selected.Canaries = nil
+	return &selected
+}
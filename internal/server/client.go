@@ -0,0 +1,53 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/elijahglover/inbound/internal/helpers"
+)
+
+// clientIP of the original client, X-Forwarded-For is only followed through trusted proxies
+func (s *Server) clientIP(req *http.Request) string {
+	ip := peerIP(req)
+	if !s.trustedPeer(req) {
+		return ip
+	}
+
+	// Walk hops right to left, the first untrusted address is the client
+	forwarded := strings.Split(strings.Join(req.Header["X-Forwarded-For"], ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(forwarded[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		ip = hop
+		if !helpers.ContainsIP(s.config.TrustedProxies, net.ParseIP(hop)) {
+			break
+		}
+	}
+	return ip
+}
+
+// isSecure checks if client connected over TLS, directly or to a trusted proxy
+func (s *Server) isSecure(req *http.Request) bool {
+	if req.TLS != nil {
+		return true
+	}
+	return s.trustedPeer(req) && strings.EqualFold(req.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+// trustedPeer checks if the connected peer is a trusted proxy
+func (s *Server) trustedPeer(req *http.Request) bool {
+	return helpers.ContainsIP(s.config.TrustedProxies, net.ParseIP(peerIP(req)))
+}
+
+// peerIP of the connected peer
+func peerIP(req *http.Request) string {
+	ip, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return ip
+}
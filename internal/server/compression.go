@@ -0,0 +1,167 @@
+package server
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/elijahglover/inbound/internal/controller"
+	"github.com/elijahglover/inbound/internal/helpers"
+)
+
+const (
+	encodingBrotli = "br"
+	encodingGzip   = "gzip"
+	// brotliLevel trades ratio for speed as responses are compressed on the fly
+	brotliLevel = 4
+)
+
+// compressResponse wraps writer to compress responses for route, returns nil when compression isn't used
+func (s *Server) compressResponse(w http.ResponseWriter, req *http.Request, route *controller.RoutePath) *compressWriter {
+	enabled := s.config.Compression
+	if route.Options.Compression != nil {
+		enabled = *route.Options.Compression
+	}
+	// Upgraded connections and gRPC frames must be passed through untouched
+	if !enabled || req.Method == http.MethodHead || req.Header.Get("Upgrade") != "" || isGRPCRequest(req) {
+		return nil
+	}
+	encoding := negotiateEncoding(req.Header.Get("Accept-Encoding"))
+	if encoding == "" {
+		return nil
+	}
+	return &compressWriter{
+		writerDelegate: writerDelegate{w},
+		encoding:       encoding,
+		minSize:        s.config.CompressionMinSize,
+		types:          s.config.CompressionTypes,
+	}
+}
+
+// negotiateEncoding picks brotli or gzip by quality, brotli wins ties
+func negotiateEncoding(acceptEncoding string) string {
+	qualities := map[string]float64{}
+	for _, item := range strings.Split(acceptEncoding, ",") {
+		parts := strings.Split(strings.TrimSpace(item), ";")
+		quality := 1.0
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if parsed, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					quality = parsed
+				}
+			}
+		}
+		qualities[strings.ToLower(strings.TrimSpace(parts[0]))] = quality
+	}
+
+	best := ""
+	bestQuality := 0.0
+	for _, encoding := range []string{encodingBrotli, encodingGzip} {
+		quality, ok := qualities[encoding]
+		if !ok {
+			quality, ok = qualities["*"]
+		}
+		if ok && quality > bestQuality {
+			best, bestQuality = encoding, quality
+		}
+	}
+	return best
+}
+
+// compressWriter decides when headers are written if the response is compressed
+// Flush flushes the encoder so streamed responses are delivered as they arrive
+type compressWriter struct {
+	writerDelegate
+	encoding    string
+	minSize     int
+	types       []string
+	encoder     io.WriteCloser
+	flusher     interface{ Flush() error }
+	wroteHeader bool
+}
+
+func (c *compressWriter) WriteHeader(status int) {
+	// Informational responses are sent ahead of the final response, as net/http does
+	if isInformational(status) {
+		c.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if c.wroteHeader {
+		return
+	}
+	c.wroteHeader = true
+
+	header := c.ResponseWriter.Header()
+	if c.compressible(status, header) {
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", c.encoding)
+		// Compressed body is no longer byte identical
+		if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			header.Set("ETag", "W/"+etag)
+		}
+
+		switch c.encoding {
+		case encodingBrotli:
+			encoder := brotli.NewWriterLevel(c.ResponseWriter, brotliLevel)
+			c.encoder, c.flusher = encoder, encoder
+		default:
+			encoder := gzip.NewWriter(c.ResponseWriter)
+			c.encoder, c.flusher = encoder, encoder
+		}
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+// compressible checks status, existing encoding, MIME type and size
+func (c *compressWriter) compressible(status int, header http.Header) bool {
+	if status < 200 || status == http.StatusNoContent || status == http.StatusPartialContent || status == http.StatusNotModified {
+		return false
+	}
+	if header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" {
+		return false
+	}
+
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil || !helpers.ContainsString(c.types, mediaType) {
+		return false
+	}
+	header.Add("Vary", "Accept-Encoding")
+
+	// Unknown length is streamed and always compressed
+	if value := header.Get("Content-Length"); value != "" {
+		if length, err := strconv.Atoi(value); err == nil && length < c.minSize {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *compressWriter) Write(b []byte) (int, error) {
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
+	}
+	if c.encoder != nil {
+		return c.encoder.Write(b)
+	}
+	return c.ResponseWriter.Write(b)
+}
+
+func (c *compressWriter) Flush() {
+	if c.flusher != nil {
+		c.flusher.Flush()
+	}
+	c.writerDelegate.Flush()
+}
+
+// Close writes the end of the compressed stream
+func (c *compressWriter) Close() error {
+	if c.encoder == nil {
+		return nil
+	}
+	return c.encoder.Close()
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/elijahglover/inbound/internal/config"
+	"github.com/elijahglover/inbound/internal/controller"
+)
+
+func Test_negotiateEncoding(t *testing.T) {
+	cases := []struct {
+		acceptEncoding string
+		expected       string
+	}{
+		{"", ""},
+		{"gzip", encodingGzip},
+		{"br", encodingBrotli},
+		{"gzip, br", encodingBrotli},
+		{"GZIP", encodingGzip},
+		{"br;q=0.5, gzip;q=0.8", encodingGzip},
+		{"br;q=0.8, gzip;q=0.8", encodingBrotli},
+		{"br;q=0, gzip", encodingGzip},
+		{"gzip;q=0", ""},
+		{"*", encodingBrotli},
+		{"gzip;q=0.5, *;q=0.1", encodingGzip},
+		{"*;q=0", ""},
+		{"identity", ""},
+		{"identity;q=0", ""},
+		{"deflate, identity;q=0", ""},
+		{"gzip, identity;q=0", encodingGzip},
+	}
+	for _, c := range cases {
+		if actual := negotiateEncoding(c.acceptEncoding); actual != c.expected {
+			t.Fatalf("unexpected output for %s %s %s", c.acceptEncoding, actual, c.expected)
+		}
+	}
+}
+
+func Test_compressWriter(t *testing.T) {
+	body := strings.Repeat("compress me ", 100)
+	cases := []struct {
+		status          int
+		contentType     string
+		contentEncoding string
+		contentLength   int
+		compressed      bool
+	}{
+		{200, "text/plain; charset=utf-8", "", 0, true},
+		{200, "text/plain", "", len(body), true},
+		{404, "text/plain", "", 0, true},
+		{200, "text/plain", "", 100, false},
+		{200, "image/png", "", 0, false},
+		{200, "", "", 0, false},
+		{200, "text/plain", "br", 0, false},
+		{204, "text/plain", "", 0, false},
+		{206, "text/plain", "", 0, false},
+		{304, "text/plain", "", 0, false},
+	}
+	for _, c := range cases {
+		recorder := httptest.NewRecorder()
+		writer := &compressWriter{writerDelegate: writerDelegate{recorder}, encoding: encodingGzip, minSize: 1024, types: []string{"text/plain"}}
+		if c.contentType != "" {
+			writer.Header().Set("Content-Type", c.contentType)
+		}
+		if c.contentEncoding != "" {
+			writer.Header().Set("Content-Encoding", c.contentEncoding)
+		}
+		if c.contentLength > 0 {
+			writer.Header().Set("Content-Length", strconv.Itoa(c.contentLength))
+		}
+		writer.Header().Set("ETag", `"v1"`)
+		writer.WriteHeader(c.status)
+		writer.Write([]byte(body))
+		writer.Close()
+
+		if compressed := recorder.Header().Get("Content-Encoding") == encodingGzip; compressed != c.compressed {
+			t.Fatalf("unexpected compression for %v %s %s %v", c.status, c.contentType, c.contentEncoding, compressed)
+		}
+		if !c.compressed {
+			if recorder.Body.String() != body || recorder.Header().Get("ETag") != `"v1"` {
+				t.Fatalf("uncompressed response modified for %v %s", c.status, c.contentType)
+			}
+			continue
+		}
+		if recorder.Header().Get("Content-Length") != "" || recorder.Header().Get("ETag") != `W/"v1"` || recorder.Header().Get("Vary") != "Accept-Encoding" {
+			t.Fatalf("unexpected headers %v", recorder.Header())
+		}
+		reader, err := gzip.NewReader(recorder.Body)
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		if decoded, _ := ioutil.ReadAll(reader); string(decoded) != body {
+			t.Fatalf("unexpected body %s", decoded)
+		}
+	}
+}
+
+func Test_compressResponse_Bypass(t *testing.T) {
+	s := &Server{config: &config.Config{Compression: true, CompressionTypes: []string{"text/plain"}}}
+	disabled := false
+
+	cases := []struct {
+		method      string
+		header      map[string]string
+		compression *bool
+		expected    bool
+	}{
+		{"GET", map[string]string{"Accept-Encoding": "gzip"}, nil, true},
+		{"GET", map[string]string{}, nil, false},
+		{"HEAD", map[string]string{"Accept-Encoding": "gzip"}, nil, false},
+		{"GET", map[string]string{"Accept-Encoding": "gzip", "Connection": "Upgrade", "Upgrade": "websocket"}, nil, false},
+		{"POST", map[string]string{"Accept-Encoding": "gzip", "Content-Type": "application/grpc"}, nil, false},
+		{"GET", map[string]string{"Accept-Encoding": "gzip"}, &disabled, false},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, "http://example.com/", nil)
+		for name, value := range c.header {
+			req.Header.Set(name, value)
+		}
+		route := &controller.RoutePath{Options: &controller.IngressOptions{Compression: c.compression}}
+		if actual := s.compressResponse(httptest.NewRecorder(), req, route) != nil; actual != c.expected {
+			t.Fatalf("unexpected output for %s %v %v %v", c.method, c.header, actual, c.expected)
+		}
+	}
+}
+
+func Test_compressWriter_Passes_Informational_Through(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	writer := &compressWriter{writerDelegate: writerDelegate{recorder}, encoding: encodingGzip, types: []string{"text/plain"}}
+	writer.Header().Set("Link", "</style.css>; rel=preload")
+	writer.WriteHeader(103)
+	writer.Header().Set("Content-Type", "text/plain")
+	writer.WriteHeader(200)
+	writer.Write([]byte("body"))
+	writer.Close()
+
+	if writer.encoder == nil || recorder.Header().Get("Content-Encoding") != encodingGzip {
+		t.Fatalf("final response not compressed after informational response")
+	}
+}
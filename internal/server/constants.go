@@ -1,6 +1,5 @@
 package server
 
 const (
-	healthcheckURL         = "/healthz"
-	acmeChallengeURLPrefix = "/.well-known/acme-challenge/"
+	healthcheckURL = "/healthz"
 )
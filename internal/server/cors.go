@@ -0,0 +1,86 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/elijahglover/inbound/internal/controller"
+	"github.com/elijahglover/inbound/internal/helpers"
+)
+
+// corsHeaders are replaced on upstream responses so services don't need to implement CORS
+var corsHeaders = []string{
+	"Access-Control-Allow-Origin",
+	"Access-Control-Allow-Credentials",
+	"Access-Control-Allow-Methods",
+	"Access-Control-Allow-Headers",
+	"Access-Control-Expose-Headers",
+	"Access-Control-Max-Age",
+}
+
+// applyCORS answers preflight requests and wraps writer to add CORS headers, returns false when request was answered
+func (s *Server) applyCORS(w http.ResponseWriter, req *http.Request, route *controller.RoutePath) (http.ResponseWriter, bool) {
+	options := route.Options.CORS
+	if options == nil {
+		return w, true
+	}
+
+	origin := req.Header.Get("Origin")
+	allowed := origin != "" && corsOriginAllowed(options.AllowOrigins, origin)
+	headers := func(header http.Header) {
+		for _, name := range corsHeaders {
+			header.Del(name)
+		}
+		header.Add("Vary", "Origin")
+		if !allowed {
+			return
+		}
+		if options.AllowCredentials || !helpers.ContainsString(options.AllowOrigins, "*") {
+			header.Set("Access-Control-Allow-Origin", origin)
+		} else {
+			header.Set("Access-Control-Allow-Origin", "*")
+		}
+		if options.AllowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if len(options.ExposeHeaders) > 0 {
+			header.Set("Access-Control-Expose-Headers", strings.Join(options.ExposeHeaders, ", "))
+		}
+	}
+
+	// Preflight requests never reach the upstream
+	if req.Method == http.MethodOptions && req.Header.Get("Access-Control-Request-Method") != "" {
+		headers(w.Header())
+		if allowed {
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(options.AllowMethods, ", "))
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(options.AllowHeaders, ", "))
+			w.Header().Set("Access-Control-Max-Age", fmt.Sprintf("%v", int(options.MaxAge.Seconds())))
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return w, false
+	}
+
+	return newHeaderHookWriter(w, headers), true
+}
+
+// corsOriginAllowed matches origin against allowed origins, * in an origin matches any subdomain
+func corsOriginAllowed(allowOrigins []string, origin string) bool {
+	for _, allowed := range allowOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+		wildcard := strings.Index(allowed, "*")
+		if wildcard < 0 {
+			continue
+		}
+		prefix, suffix := allowed[:wildcard], allowed[wildcard+1:]
+		if len(origin) > len(prefix)+len(suffix) &&
+			strings.HasPrefix(strings.ToLower(origin), strings.ToLower(prefix)) &&
+			strings.HasSuffix(strings.ToLower(origin), strings.ToLower(suffix)) &&
+			!strings.ContainsAny(origin[len(prefix):len(origin)-len(suffix)], "/:") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/elijahglover/inbound/internal/controller"
+)
+
+func Test_corsOriginAllowed(t *testing.T) {
+	cases := []struct {
+		allowOrigins []string
+		origin       string
+		expected     bool
+	}{
+		{[]string{"*"}, "https://anything.com", true},
+		{[]string{"https://example.com"}, "https://example.com", true},
+		{[]string{"https://example.com"}, "HTTPS://EXAMPLE.COM", true},
+		{[]string{"https://example.com"}, "https://example.com.evil.com", false},
+		{[]string{"https://*.example.com"}, "https://app.example.com", true},
+		{[]string{"https://*.example.com"}, "https://a.b.example.com", true},
+		{[]string{"https://*.example.com"}, "https://example.com", false},
+		{[]string{"https://*.example.com"}, "https://evil.com/.example.com", false},
+		{[]string{"https://*.example.com"}, "https://app.example.com:8443", false},
+		{[]string{"https://*.example.com"}, "http://app.example.com", false},
+		{nil, "https://example.com", false},
+	}
+	for _, c := range cases {
+		if actual := corsOriginAllowed(c.allowOrigins, c.origin); actual != c.expected {
+			t.Fatalf("unexpected output for %v %s %v %v", c.allowOrigins, c.origin, actual, c.expected)
+		}
+	}
+}
+
+func Test_applyCORS_Response_Headers(t *testing.T) {
+	s := &Server{}
+	cases := []struct {
+		options  controller.CORSOptions
+		origin   string
+		expected string
+	}{
+		{controller.CORSOptions{AllowOrigins: []string{"*"}}, "https://app.com", "*"},
+		// Credentials can't be used with *, origin is echoed instead
+		{controller.CORSOptions{AllowOrigins: []string{"*"}, AllowCredentials: true}, "https://app.com", "https://app.com"},
+		{controller.CORSOptions{AllowOrigins: []string{"https://app.com"}}, "https://app.com", "https://app.com"},
+		{controller.CORSOptions{AllowOrigins: []string{"https://app.com"}}, "https://evil.com", ""},
+		{controller.CORSOptions{AllowOrigins: []string{"*"}}, "", ""},
+	}
+	for _, c := range cases {
+		options := c.options
+		route := &controller.RoutePath{Options: &controller.IngressOptions{CORS: &options}}
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		if c.origin != "" {
+			req.Header.Set("Origin", c.origin)
+		}
+
+		recorder := httptest.NewRecorder()
+		w, proceed := s.applyCORS(recorder, req, route)
+		if !proceed {
+			t.Fatalf("unexpected preflight handling for %s", req.Method)
+		}
+
+		// Upstream CORS headers are replaced
+		w.Header().Set("Access-Control-Allow-Origin", "https://upstream.com")
+		w.WriteHeader(200)
+
+		if actual := recorder.Header().Get("Access-Control-Allow-Origin"); actual != c.expected {
+			t.Fatalf("unexpected allow origin for %v %s %s %s", options.AllowOrigins, c.origin, actual, c.expected)
+		}
+		if actual := recorder.Header().Get("Access-Control-Allow-Credentials"); (actual == "true") != (options.AllowCredentials && c.expected != "") {
+			t.Fatalf("unexpected allow credentials %s", actual)
+		}
+		if actual := recorder.Header().Get("Vary"); actual != "Origin" {
+			t.Fatalf("unexpected vary %s", actual)
+		}
+	}
+}
+
+func Test_applyCORS_Preflight(t *testing.T) {
+	s := &Server{}
+	route := &controller.RoutePath{Options: &controller.IngressOptions{CORS: &controller.CORSOptions{
+		AllowOrigins: []string{"https://*.example.com"},
+		AllowMethods: []string{"GET", "POST"},
+		AllowHeaders: []string{"Authorization"},
+		MaxAge:       10 * time.Minute,
+	}}}
+
+	req := httptest.NewRequest("OPTIONS", "http://api.example.com/", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	recorder := httptest.NewRecorder()
+	if _, proceed := s.applyCORS(recorder, req, route); proceed {
+		t.Fatalf("preflight not answered")
+	}
+
+	if recorder.Code != 204 {
+		t.Fatalf("unexpected status %v", recorder.Code)
+	}
+	for name, expected := range map[string]string{
+		"Access-Control-Allow-Origin":  "https://app.example.com",
+		"Access-Control-Allow-Methods": "GET, POST",
+		"Access-Control-Allow-Headers": "Authorization",
+		"Access-Control-Max-Age":       "600",
+		"Vary":                         "Origin",
+	} {
+		if actual := recorder.Header().Get(name); actual != expected {
+			t.Fatalf("unexpected output for %s %s %s", name, actual, expected)
+		}
+	}
+
+	// Plain OPTIONS requests are proxied
+	req.Header.Del("Access-Control-Request-Method")
+	if _, proceed := s.applyCORS(httptest.NewRecorder(), req, route); !proceed {
+		t.Fatalf("options request without preflight answered")
+	}
+}
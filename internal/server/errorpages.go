@@ -0,0 +1,99 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/elijahglover/inbound/internal/controller"
+	"github.com/elijahglover/inbound/internal/errorpages"
+	"github.com/elijahglover/inbound/internal/helpers"
+)
+
+// upstreamEntityHeaders describe the upstream body and are dropped when it is replaced
+var upstreamEntityHeaders = []string{"Content-Length", "Content-Type", "Content-Encoding", "Content-Range", "ETag", "Last-Modified"}
+
+// writeErrorPage renders a configured error page, returns false when no page matches the status and accept header
+func (s *Server) writeErrorPage(w http.ResponseWriter, req *http.Request, status int, message string) bool {
+	pages := s.controller.GetErrorPages()
+	if pages == nil {
+		return false
+	}
+
+	contentType, body, ok := pages.Render(req.Header.Get("Accept"), errorpages.Data{
+		Status:     status,
+		StatusText: http.StatusText(status),
+		Message:    message,
+		Host:       helpers.ExtractHostname(req.Host),
+		Path:       req.URL.Path,
+	})
+	if !ok {
+		return false
+	}
+
+	for _, name := range upstreamEntityHeaders {
+		w.Header().Del(name)
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(status)
+	w.Write(body)
+	return true
+}
+
+// interceptErrors checks if upstream 5xx responses are replaced for route
+func (s *Server) interceptErrors(req *http.Request, route *controller.RoutePath) bool {
+	if isGRPCRequest(req) {
+		return false
+	}
+	if route.Options.InterceptErrors != nil {
+		return *route.Options.InterceptErrors
+	}
+	return s.config.InterceptErrors
+}
+
+// errorPageWriter replaces upstream 5xx responses with error pages
+type errorPageWriter struct {
+	writerDelegate
+	server      *Server
+	req         *http.Request
+	wroteHeader bool
+	intercepted bool
+}
+
+func newErrorPageWriter(s *Server, w http.ResponseWriter, req *http.Request) *errorPageWriter {
+	return &errorPageWriter{writerDelegate: writerDelegate{w}, server: s, req: req}
+}
+
+func (e *errorPageWriter) WriteHeader(status int) {
+	if isInformational(status) {
+		e.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if e.wroteHeader {
+		return
+	}
+	e.wroteHeader = true
+	if status >= 500 && e.server.writeErrorPage(e.ResponseWriter, e.req, status, http.StatusText(status)) {
+		e.intercepted = true
+		return
+	}
+	e.ResponseWriter.WriteHeader(status)
+}
+
+func (e *errorPageWriter) Write(b []byte) (int, error) {
+	if !e.wroteHeader {
+		e.WriteHeader(http.StatusOK)
+	}
+	// Upstream body is discarded once replaced
+	if e.intercepted {
+		return len(b), nil
+	}
+	return e.ResponseWriter.Write(b)
+}
+
+func (e *errorPageWriter) Flush() {
+	if e.intercepted {
+		return
+	}
+	e.writerDelegate.Flush()
+}
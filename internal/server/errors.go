@@ -0,0 +1,48 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+var errNoUpstream = fmt.Errorf("No upstream available")
+
+// circuitOpenError is returned when every candidate upstream has an open circuit
+type circuitOpenError struct {
+	retryAfter time.Duration
+}
+
+func (e *circuitOpenError) Error() string {
+	return fmt.Sprintf("Circuit open, retry after %s", e.retryAfter)
+}
+
+// writeError responds with a proxy generated error in a format the client understands
+func (s *Server) writeError(w http.ResponseWriter, req *http.Request, status int, message string) {
+	if isGRPCRequest(req) {
+		writeGRPCError(w, req, status, message)
+		return
+	}
+	if s.writeErrorPage(w, req, status, message) {
+		return
+	}
+	w.WriteHeader(status)
+	w.Write([]byte(message + "\n"))
+}
+
+// handleProxyError maps upstream failures to proxy generated errors so error pages are used
+func (s *Server) handleProxyError(w http.ResponseWriter, req *http.Request, err error) {
+	s.logger.Infof("Error proxying request to %s %s", req.URL.Host, err)
+	if netErr, ok := err.(net.Error); (ok && netErr.Timeout()) || req.Context().Err() == context.DeadlineExceeded {
+		s.writeError(w, req, 504, "Upstream timed out")
+		return
+	}
+	// Client went away, nothing to respond to
+	if req.Context().Err() == context.Canceled {
+		w.WriteHeader(499)
+		return
+	}
+	s.writeError(w, req, 502, "Upstream unavailable")
+}
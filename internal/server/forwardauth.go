@@ -0,0 +1,86 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/elijahglover/inbound/internal/controller"
+)
+
+// maxAuthResponseBody limits the denied auth response body relayed to the client
+const maxAuthResponseBody = 1 << 20
+
+// allowForwardAuth asks the auth service to authorize the request, relays its response and returns false when rejected
+func (s *Server) allowForwardAuth(w http.ResponseWriter, req *http.Request, route *controller.RoutePath) bool {
+	options := route.Options.ForwardAuth
+	if options == nil {
+		return true
+	}
+
+	// Clients must not be able to supply headers the auth service vouches for
+	for _, name := range options.ResponseHeaders {
+		req.Header.Del(name)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), options.Timeout)
+	defer cancel()
+
+	authReq, err := http.NewRequest(http.MethodGet, options.URL, nil)
+	if err != nil {
+		s.logger.Infof("Unable to create auth request for %s %s %s", route.Ingress, route.Path, err)
+		s.writeError(w, req, 502, "Authentication service unavailable")
+		return false
+	}
+	authReq = authReq.WithContext(ctx)
+	for _, name := range options.RequestHeaders {
+		for _, value := range req.Header[http.CanonicalHeaderKey(name)] {
+			authReq.Header.Add(name, value)
+		}
+	}
+	scheme := "http"
+	if s.isSecure(req) {
+		scheme = "https"
+	}
+	authReq.Header.Set("X-Forwarded-Method", req.Method)
+	authReq.Header.Set("X-Forwarded-Proto", scheme)
+	authReq.Header.Set("X-Forwarded-Host", req.Host)
+	authReq.Header.Set("X-Forwarded-Uri", req.URL.RequestURI())
+	authReq.Header.Set("X-Forwarded-For", s.clientIP(req))
+
+	resp, err := s.authClient.Do(authReq)
+	if err != nil {
+		s.logger.Infof("Auth request for %s %s failed %s", route.Ingress, route.Path, err)
+		s.writeError(w, req, 502, "Authentication service unavailable")
+		return false
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		for _, name := range options.ResponseHeaders {
+			for _, value := range resp.Header[http.CanonicalHeaderKey(name)] {
+				req.Header.Add(name, value)
+			}
+		}
+		return true
+	}
+
+	s.logger.Verbosef("Auth service denied %s %s with %v", route.Ingress, route.Path, resp.StatusCode)
+
+	// gRPC clients only understand a grpc-status, the auth response can't be relayed
+	if isGRPCRequest(req) {
+		s.writeError(w, req, resp.StatusCode, "Authentication denied")
+		return false
+	}
+
+	// Relay denial such as a redirect to the login page
+	for name, values := range resp.Header {
+		if name == "Content-Length" || name == "Connection" || name == "Transfer-Encoding" {
+			continue
+		}
+		w.Header()[name] = values
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, io.LimitReader(resp.Body, maxAuthResponseBody))
+	return false
+}
@@ -0,0 +1,173 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/elijahglover/inbound/internal/config"
+	"github.com/elijahglover/inbound/internal/controller"
+	"github.com/elijahglover/inbound/internal/logger"
+)
+
+func newForwardAuthTest(authURL string, timeout time.Duration) (*Server, *controller.RoutePath) {
+	s := &Server{
+		logger:     logger.NewNull(),
+		config:     &config.Config{},
+		controller: &fakeController{},
+		authClient: &http.Client{
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+	route := &controller.RoutePath{Options: &controller.IngressOptions{ForwardAuth: &controller.ForwardAuthOptions{
+		URL:             authURL,
+		RequestHeaders:  []string{"Authorization"},
+		ResponseHeaders: []string{"X-User"},
+		Timeout:         timeout,
+	}}}
+	return s, route
+}
+
+func Test_allowForwardAuth_Allowed(t *testing.T) {
+	var authHeader http.Header
+	auth := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		authHeader = req.Header
+		w.Header().Set("X-User", "alice")
+		w.Header().Set("X-Internal", "secret")
+		w.WriteHeader(204)
+	}))
+	defer auth.Close()
+	s, route := newForwardAuthTest(auth.URL, time.Second)
+
+	req := httptest.NewRequest("GET", "http://example.com/admin?page=1", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("Cookie", "session=1")
+	req.Header.Set("X-User", "mallory")
+	recorder := httptest.NewRecorder()
+
+	if !s.allowForwardAuth(recorder, req, route) {
+		t.Fatalf("expected request to be allowed")
+	}
+	if recorder.Body.Len() > 0 || len(recorder.Header()) > 0 {
+		t.Fatalf("unexpected response written %v %s", recorder.Header(), recorder.Body.String())
+	}
+
+	// Only configured request headers are sent to the auth service
+	if authHeader.Get("Authorization") != "Bearer token" || authHeader.Get("Cookie") != "" {
+		t.Fatalf("unexpected auth request headers %v", authHeader)
+	}
+	if authHeader.Get("X-Forwarded-Uri") != "/admin?page=1" || authHeader.Get("X-Forwarded-Method") != "GET" || authHeader.Get("X-Forwarded-Host") != "example.com" {
+		t.Fatalf("unexpected auth forwarded headers %v", authHeader)
+	}
+
+	// Configured response headers replace client supplied copies
+	if values := req.Header["X-User"]; len(values) != 1 || values[0] != "alice" {
+		t.Fatalf("unexpected upstream X-User %v", values)
+	}
+	if req.Header.Get("X-Internal") != "" {
+		t.Fatalf("unconfigured auth response header copied upstream")
+	}
+}
+
+func Test_allowForwardAuth_Strips_Client_Headers(t *testing.T) {
+	// Auth service vouches for nobody, client copies must not reach the upstream
+	auth := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(200)
+	}))
+	defer auth.Close()
+	s, route := newForwardAuthTest(auth.URL, time.Second)
+
+	req := httptest.NewRequest("GET", "http://example.com/", nil)
+	req.Header.Set("x-user", "mallory")
+	if !s.allowForwardAuth(httptest.NewRecorder(), req, route) {
+		t.Fatalf("expected request to be allowed")
+	}
+	if req.Header.Get("X-User") != "" {
+		t.Fatalf("client supplied header not stripped %s", req.Header.Get("X-User"))
+	}
+}
+
+func Test_allowForwardAuth_Denied(t *testing.T) {
+	cases := []struct {
+		status   int
+		location string
+		body     string
+	}{
+		{302, "https://login.example.com/", "redirecting"},
+		{401, "", "unauthorized"},
+		{403, "", "forbidden"},
+	}
+	for _, c := range cases {
+		auth := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if c.location != "" {
+				w.Header().Set("Location", c.location)
+			}
+			w.Header().Set("X-User", "alice")
+			w.WriteHeader(c.status)
+			w.Write([]byte(c.body))
+		}))
+		s, route := newForwardAuthTest(auth.URL, time.Second)
+
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		recorder := httptest.NewRecorder()
+		if s.allowForwardAuth(recorder, req, route) {
+			t.Fatalf("expected request to be denied for %v", c.status)
+		}
+		if recorder.Code != c.status || recorder.Body.String() != c.body || recorder.Header().Get("Location") != c.location {
+			t.Fatalf("unexpected response for %v %v %s %v", c.status, recorder.Code, recorder.Body.String(), recorder.Header())
+		}
+		if req.Header.Get("X-User") != "" {
+			t.Fatalf("denied auth response header copied upstream for %v", c.status)
+		}
+		auth.Close()
+	}
+}
+
+func Test_allowForwardAuth_Unavailable(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		select {
+		case <-req.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer slow.Close()
+	refused := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	refused.Close()
+
+	for _, authURL := range []string{slow.URL, refused.URL} {
+		s, route := newForwardAuthTest(authURL, 20*time.Millisecond)
+		recorder := httptest.NewRecorder()
+		if s.allowForwardAuth(recorder, httptest.NewRequest("GET", "http://example.com/", nil), route) {
+			t.Fatalf("expected request to be denied for %s", authURL)
+		}
+		if recorder.Code != 502 {
+			t.Fatalf("unexpected status for %s %v", authURL, recorder.Code)
+		}
+	}
+}
+
+func Test_allowForwardAuth_Denied_GRPC(t *testing.T) {
+	auth := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(403)
+		w.Write([]byte("<h1>forbidden</h1>"))
+	}))
+	defer auth.Close()
+	s, route := newForwardAuthTest(auth.URL, time.Second)
+
+	req := httptest.NewRequest("POST", "http://example.com/pkg.Service/Method", nil)
+	req.Header.Set("Content-Type", "application/grpc")
+	recorder := httptest.NewRecorder()
+	if s.allowForwardAuth(recorder, req, route) {
+		t.Fatalf("expected request to be denied")
+	}
+	if recorder.Code != 200 || recorder.Header().Get("Grpc-Status") != "7" || recorder.Header().Get("Content-Type") != "application/grpc" {
+		t.Fatalf("unexpected response %v %v", recorder.Code, recorder.Header())
+	}
+	if recorder.Body.Len() > 0 {
+		t.Fatalf("auth response body relayed to gRPC client %s", recorder.Body.String())
+	}
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"time"
+
+	"github.com/elijahglover/inbound/internal/controller"
+	"github.com/vulcand/oxy/forward"
+	"github.com/vulcand/oxy/utils"
+	"golang.org/x/net/http2"
+)
+
+// forwarderSettings identifies a forwarder by its transport settings
+type forwarderSettings struct {
+	protocol string
+	connect  time.Duration
+	response time.Duration
+	idle     time.Duration
+}
+
+// forwarder returns a cached forwarder matching route protocol and timeouts
+func (s *Server) forwarder(route *controller.RoutePath) http.Handler {
+	settings := forwarderSettings{
+		protocol: route.Options.BackendProtocol,
+		connect:  s.config.UpstreamConnectTimeout,
+		response: s.config.UpstreamResponseTimeout,
+		idle:     s.config.UpstreamIdleTimeout,
+	}
+	if route.Options.Timeouts.Connect > 0 {
+		settings.connect = route.Options.Timeouts.Connect
+	}
+	if route.Options.Timeouts.Response > 0 {
+		settings.response = route.Options.Timeouts.Response
+	}
+	if route.Options.Timeouts.Idle > 0 {
+		settings.idle = route.Options.Timeouts.Idle
+	}
+
+	s.forwardersLock.Lock()
+	defer s.forwardersLock.Unlock()
+
+	if fwd, ok := s.forwarders[settings]; ok {
+		return fwd
+	}
+	fwd := s.newForwarder(settings)
+	s.forwarders[settings] = fwd
+	return fwd
+}
+
+func (s *Server) newForwarder(settings forwarderSettings) http.Handler {
+	transport := newTransport(settings)
+
+	// Don't trust anything up stream as this is internet facing
+	rewriter := &forward.HeaderRewriter{TrustForwardHeader: false}
+
+	if settings.protocol == controller.BackendProtocolGRPC {
+		// Forwarder drops trailers which carry grpc-status, use the standard library proxy instead
+		// Client address is appended to X-Forwarded-For by the proxy after forwarded headers are replaced
+		return &httputil.ReverseProxy{
+			Director:      rewriter.Rewrite,
+			Transport:     transport,
+			FlushInterval: 10 * time.Millisecond,
+			ErrorLog:      s.httpLogger,
+			ErrorHandler:  s.handleGRPCProxyError,
+		}
+	}
+
+	fwd, _ := forward.New(
+		forward.RoundTripper(transport),
+		forward.Stream(true),
+		forward.StreamingFlushInterval(100*time.Millisecond),
+		forward.PassHostHeader(true),
+		forward.Rewriter(rewriter),
+		forward.ErrorHandler(utils.ErrorHandlerFunc(s.handleProxyError)),
+	)
+	return fwd
+}
+
+// newTransport creates the upstream transport for protocol applying connect, response and idle timeouts
+func newTransport(settings forwarderSettings) http.RoundTripper {
+	dialer := &net.Dialer{
+		Timeout:   settings.connect,
+		KeepAlive: 30 * time.Second,
+	}
+
+	switch settings.protocol {
+	case controller.BackendProtocolGRPC, controller.BackendProtocolH2C:
+		// Cleartext HTTP/2, dial without TLS
+		return &responseTimeoutTransport{
+			timeout: settings.response,
+			transport: &http2.Transport{
+				AllowHTTP: true,
+				DialTLS: func(network string, addr string, cfg *tls.Config) (net.Conn, error) {
+					return dialer.Dial(network, addr)
+				},
+				IdleConnTimeout: settings.idle,
+			},
+		}
+	}
+	return &http.Transport{
+		DialContext:           dialer.DialContext,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       settings.idle,
+		ResponseHeaderTimeout: settings.response,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// responseTimeoutTransport limits time waiting for response headers on transports without native support
+type responseTimeoutTransport struct {
+	timeout   time.Duration
+	transport http.RoundTripper
+}
+
+func (t *responseTimeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.timeout <= 0 {
+		return t.transport.RoundTrip(req)
+	}
+
+	// Not a context deadline as streamed response bodies may outlive the timeout
+	ctx, cancel := context.WithCancel(req.Context())
+	timer := time.AfterFunc(t.timeout, cancel)
+	resp, err := t.transport.RoundTrip(req.WithContext(ctx))
+	expired := !timer.Stop()
+	if err != nil {
+		cancel()
+		// Report expiry as a deadline so clients receive 504 or DEADLINE_EXCEEDED
+		if expired && req.Context().Err() == nil {
+			return nil, context.DeadlineExceeded
+		}
+		return nil, err
+	}
+
+	// Context must live until the body has been read
+	resp.Body = &cancelOnCloseBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
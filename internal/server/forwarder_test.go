@@ -0,0 +1,162 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elijahglover/inbound/internal/config"
+	"github.com/elijahglover/inbound/internal/controller"
+	"github.com/elijahglover/inbound/internal/errorpages"
+	"github.com/elijahglover/inbound/internal/logger"
+	"golang.org/x/net/http2"
+)
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func Test_responseTimeoutTransport_Expired(t *testing.T) {
+	transport := &responseTimeoutTransport{
+		timeout: 10 * time.Millisecond,
+		transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			<-req.Context().Done()
+			return nil, req.Context().Err()
+		}),
+	}
+
+	_, err := transport.RoundTrip(httptest.NewRequest("GET", "http://example.com/", nil))
+	if err != context.DeadlineExceeded {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// Client cancellation is not reported as a timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = transport.RoundTrip(httptest.NewRequest("GET", "http://example.com/", nil).WithContext(ctx))
+	if err != context.Canceled {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func Test_responseTimeoutTransport_Streams_Body(t *testing.T) {
+	var upstreamCtx context.Context
+	transport := &responseTimeoutTransport{
+		timeout: 10 * time.Millisecond,
+		transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			upstreamCtx = req.Context()
+			return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(strings.NewReader("stream"))}, nil
+		}),
+	}
+
+	resp, err := transport.RoundTrip(httptest.NewRequest("GET", "http://example.com/", nil))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	// Timeout stops applying once headers have been received
+	time.Sleep(20 * time.Millisecond)
+	if upstreamCtx.Err() != nil {
+		t.Fatalf("body cancelled after headers received %s", upstreamCtx.Err())
+	}
+	resp.Body.Close()
+	if upstreamCtx.Err() == nil {
+		t.Fatalf("context not released when body closed")
+	}
+}
+
+func Test_newForwarder_GRPC_Replaces_Forwarded_Headers(t *testing.T) {
+	s := &Server{}
+	proxy, ok := s.newForwarder(forwarderSettings{protocol: controller.BackendProtocolGRPC}).(*httputil.ReverseProxy)
+	if !ok {
+		t.Fatalf("expected reverse proxy for gRPC")
+	}
+
+	req := httptest.NewRequest("POST", "http://example.com/pkg.Service/Method", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("X-Forwarded-For", "10.0.0.1")
+	req.Header.Set("X-Forwarded-Host", "spoofed.example.com")
+
+	var upstreamHeader http.Header
+	proxy.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		upstreamHeader = req.Header
+		return &http.Response{StatusCode: 200, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+	})
+	proxy.ServeHTTP(httptest.NewRecorder(), req)
+
+	if actual := upstreamHeader.Get("X-Forwarded-For"); actual != "192.0.2.1" {
+		t.Fatalf("unexpected X-Forwarded-For %s", actual)
+	}
+	if actual := upstreamHeader.Get("X-Forwarded-Host"); actual == "spoofed.example.com" {
+		t.Fatalf("unexpected X-Forwarded-Host %s", actual)
+	}
+}
+
+func Test_newForwarder_Refused_Upstream_Error_Page(t *testing.T) {
+	pages, _ := errorpages.Parse(map[string]string{"502.html": "<h1>{{.Status}} {{.StatusText}}</h1>"})
+	s := &Server{logger: logger.NewNull(), config: &config.Config{}, controller: &fakeController{errorPages: pages}}
+
+	// Closed server leaves nothing listening on the address
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	upstream.Close()
+
+	for _, protocol := range []string{"", controller.BackendProtocolH2C} {
+		req := httptest.NewRequest("GET", upstream.URL+"/", nil)
+		recorder := httptest.NewRecorder()
+		s.newForwarder(forwarderSettings{protocol: protocol}).ServeHTTP(recorder, req)
+
+		if recorder.Code != 502 || recorder.Body.String() != "<h1>502 Bad Gateway</h1>" {
+			t.Fatalf("unexpected response for %s %v %s", protocol, recorder.Code, recorder.Body.String())
+		}
+	}
+
+	// Without a page the error is still written by the proxy
+	s.controller = &fakeController{}
+	recorder := httptest.NewRecorder()
+	s.newForwarder(forwarderSettings{}).ServeHTTP(recorder, httptest.NewRequest("GET", upstream.URL+"/", nil))
+	if recorder.Code != 502 || recorder.Body.String() != "Upstream unavailable\n" {
+		t.Fatalf("unexpected response %v %s", recorder.Code, recorder.Body.String())
+	}
+}
+
+func Test_newTransport_Timeouts(t *testing.T) {
+	for _, protocol := range []string{"", controller.BackendProtocolH2C, controller.BackendProtocolGRPC} {
+		settings := forwarderSettings{protocol: protocol, connect: time.Second, response: 2 * time.Second, idle: 3 * time.Second}
+
+		switch transport := newTransport(settings).(type) {
+		case *http.Transport:
+			if protocol != "" {
+				t.Fatalf("unexpected HTTP/1.1 transport for %s", protocol)
+			}
+			if transport.ResponseHeaderTimeout != settings.response || transport.IdleConnTimeout != settings.idle {
+				t.Fatalf("unexpected timeouts for %s %s %s", protocol, transport.ResponseHeaderTimeout, transport.IdleConnTimeout)
+			}
+		case *responseTimeoutTransport:
+			h2Transport, ok := transport.transport.(*http2.Transport)
+			if protocol == "" || !ok || !h2Transport.AllowHTTP {
+				t.Fatalf("unexpected h2c transport for %s", protocol)
+			}
+			if transport.timeout != settings.response || h2Transport.IdleConnTimeout != settings.idle {
+				t.Fatalf("unexpected timeouts for %s %s %s", protocol, transport.timeout, h2Transport.IdleConnTimeout)
+			}
+		default:
+			t.Fatalf("unexpected transport for %s %T", protocol, transport)
+		}
+	}
+
+	// gRPC is proxied by the standard library using the h2c transport
+	s := &Server{}
+	proxy := s.newForwarder(forwarderSettings{protocol: controller.BackendProtocolGRPC, idle: time.Second}).(*httputil.ReverseProxy)
+	transport, ok := proxy.Transport.(*responseTimeoutTransport)
+	if !ok || transport.transport.(*http2.Transport).IdleConnTimeout != time.Second {
+		t.Fatalf("unexpected gRPC transport %T", proxy.Transport)
+	}
+}
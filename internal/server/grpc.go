@@ -0,0 +1,68 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// gRPC status codes, see https://github.com/grpc/grpc/blob/master/doc/statuscodes.md
+const (
+	grpcStatusUnknown           = 2
+	grpcStatusDeadlineExceeded  = 4
+	grpcStatusPermissionDenied  = 7
+	grpcStatusResourceExhausted = 8
+	grpcStatusUnimplemented     = 12
+	grpcStatusInternal          = 13
+	grpcStatusUnavailable       = 14
+	grpcStatusUnauthenticated   = 16
+)
+
+func isGRPCRequest(req *http.Request) bool {
+	return strings.HasPrefix(req.Header.Get("Content-Type"), "application/grpc")
+}
+
+// grpcStatusFromHTTP maps proxy generated http status codes to gRPC status codes
+func grpcStatusFromHTTP(status int) int {
+	switch status {
+	case 400:
+		return grpcStatusInternal
+	case 401:
+		return grpcStatusUnauthenticated
+	case 403:
+		return grpcStatusPermissionDenied
+	case 404:
+		return grpcStatusUnimplemented
+	case 429:
+		return grpcStatusResourceExhausted
+	case 502, 503:
+		return grpcStatusUnavailable
+	case 504:
+		return grpcStatusDeadlineExceeded
+	}
+	return grpcStatusUnknown
+}
+
+// writeGRPCError responds with a trailers only gRPC response, clients ignore the http status
+// The http status is recorded against the request so failures are still seen by circuit breaking, metrics and access logs
+func writeGRPCError(w http.ResponseWriter, req *http.Request, status int, message string) {
+	if info := requestInfoFrom(req); info != nil {
+		info.grpcErrorStatus = status
+	}
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Grpc-Status", fmt.Sprintf("%v", grpcStatusFromHTTP(status)))
+	w.Header().Set("Grpc-Message", url.PathEscape(message))
+	w.WriteHeader(200)
+}
+
+// handleGRPCProxyError maps upstream failures to gRPC errors
+func (s *Server) handleGRPCProxyError(w http.ResponseWriter, req *http.Request, err error) {
+	s.logger.Infof("Error proxying gRPC request to %s %s", req.URL.Host, err)
+	if err == context.DeadlineExceeded || req.Context().Err() == context.DeadlineExceeded {
+		writeGRPCError(w, req, 504, "Upstream timed out")
+		return
+	}
+	writeGRPCError(w, req, 503, "Upstream unavailable")
+}
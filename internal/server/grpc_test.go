@@ -0,0 +1,31 @@
+package server
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/elijahglover/inbound/internal/config"
+	"github.com/elijahglover/inbound/internal/logger"
+)
+
+func Test_handleGRPCProxyError_Records_Status(t *testing.T) {
+	s := &Server{logger: logger.NewNull(), config: &config.Config{}}
+	info := &requestInfo{start: time.Now()}
+	req := withRequestInfo(httptest.NewRequest("POST", "http://example.com/pkg.Service/Method", nil), info)
+	req.Header.Set("Content-Type", "application/grpc")
+
+	recorder := newResponseRecorder(httptest.NewRecorder())
+	s.handleGRPCProxyError(recorder, req, errors.New("connection refused"))
+
+	if recorder.Status() != 200 || recorder.Header().Get("Grpc-Status") != "14" {
+		t.Fatalf("unexpected gRPC response %v %s", recorder.Status(), recorder.Header().Get("Grpc-Status"))
+	}
+	if actual := info.status(recorder); actual != 503 {
+		t.Fatalf("unexpected recorded status %v", actual)
+	}
+	if actual := s.accessLogEntry(req, recorder, info).Status; actual != 503 {
+		t.Fatalf("unexpected access log status %v", actual)
+	}
+}
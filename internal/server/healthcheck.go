@@ -0,0 +1,72 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/elijahglover/inbound/internal/controller"
+	"github.com/elijahglover/inbound/internal/healthcheck"
+)
+
+const healthCheckSyncInterval = 5 * time.Second
+
+// syncHealthChecks keeps probed targets in line with the route table and discovered endpoints
+func (s *Server) syncHealthChecks(ctx context.Context) {
+	ticker := time.NewTicker(healthCheckSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		s.healthChecker.Sync(ctx, s.healthCheckTargets())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Server) healthCheckTargets() []healthcheck.Target {
+	seen := map[string]bool{}
+	targets := make([]healthcheck.Target, 0)
+
+	routes := []controller.RoutePath{}
+	for _, routeTable := range s.controller.GetRouteTables() {
+		routes = append(routes, routeTable.Paths...)
+		if routeTable.Default != nil {
+			routes = append(routes, *routeTable.Default)
+		}
+	}
+	if route := s.controller.GetDefaultRoute(); route != nil {
+		routes = append(routes, *route)
+	}
+	for _, route := range routes {
+		for _, canary := range route.Canaries {
+			routes = append(routes, *canaryRoute(&route, canary))
+		}
+	}
+
+	for _, route := range routes {
+		options := route.Options.HealthCheck
+		if options == nil {
+			continue
+		}
+
+		for _, address := range s.controller.GetEndpoints(route.ServiceName, route.ServicePort) {
+			// First route referencing an endpoint decides how it is probed
+			if seen[address] {
+				continue
+			}
+			seen[address] = true
+			targets = append(targets, healthcheck.Target{
+				Address:            address,
+				Path:               options.Path,
+				Interval:           options.Interval,
+				Timeout:            options.Timeout,
+				UnhealthyThreshold: options.UnhealthyThreshold,
+				HealthyThreshold:   options.HealthyThreshold,
+			})
+		}
+	}
+	return targets
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/elijahglover/inbound/internal/acme"
+	"github.com/elijahglover/inbound/internal/controller"
+	"github.com/elijahglover/inbound/internal/helpers"
+)
+
+// applyHTTPSPolicy redirects plain HTTP to HTTPS and adds HSTS, returns false when the request was redirected
+func (s *Server) applyHTTPSPolicy(w http.ResponseWriter, req *http.Request, route *controller.RoutePath) bool {
+	options := route.Options.HTTPS
+
+	if !s.isSecure(req) {
+		redirect := s.config.SSLRedirect
+		if options.Redirect != nil {
+			redirect = *options.Redirect
+		}
+		// ACME challenges must be answered over HTTP
+		if !redirect || strings.HasPrefix(req.URL.Path, acme.ChallengeURLPrefix) {
+			return true
+		}
+
+		status := s.config.SSLRedirectStatus
+		if options.RedirectStatus != 0 {
+			status = options.RedirectStatus
+		}
+		port := s.config.SSLRedirectPort
+		if options.RedirectPort != "" {
+			port = options.RedirectPort
+		}
+
+		host := helpers.ExtractHostname(req.Host)
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		w.Header().Set("Location", "https://"+host+req.URL.RequestURI())
+		w.WriteHeader(status)
+		return false
+	}
+
+	// HSTS is ignored by browsers over plain HTTP
+	maxAge := s.config.HSTSMaxAge
+	if options.HSTSMaxAge != nil {
+		maxAge = *options.HSTSMaxAge
+	}
+	if maxAge <= 0 {
+		return true
+	}
+	includeSubDomains := s.config.HSTSIncludeSubDomains
+	if options.HSTSIncludeSubDomains != nil {
+		includeSubDomains = *options.HSTSIncludeSubDomains
+	}
+	preload := s.config.HSTSPreload
+	if options.HSTSPreload != nil {
+		preload = *options.HSTSPreload
+	}
+
+	value := fmt.Sprintf("max-age=%v", maxAge)
+	if includeSubDomains {
+		value += "; includeSubDomains"
+	}
+	if preload {
+		value += "; preload"
+	}
+	w.Header().Set("Strict-Transport-Security", value)
+	return true
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"crypto/tls"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/elijahglover/inbound/internal/acme"
+	"github.com/elijahglover/inbound/internal/config"
+	"github.com/elijahglover/inbound/internal/controller"
+)
+
+func Test_applyHTTPSPolicy_Redirect(t *testing.T) {
+	enabled, disabled := true, false
+
+	cases := []struct {
+		name     string
+		redirect bool
+		status   int
+		port     string
+		options  controller.HTTPSOptions
+		url      string
+		expected int
+		location string
+	}{
+		{"disabled", false, 308, "443", controller.HTTPSOptions{}, "http://example.com/a?b=c", 0, ""},
+		{"enabled", true, 308, "443", controller.HTTPSOptions{}, "http://example.com/a?b=c", 308, "https://example.com/a?b=c"},
+		{"host port dropped", true, 308, "443", controller.HTTPSOptions{}, "http://example.com:8080/a", 308, "https://example.com/a"},
+		{"configured status", true, 301, "443", controller.HTTPSOptions{}, "http://example.com/a", 301, "https://example.com/a"},
+		{"non default port", true, 308, "8443", controller.HTTPSOptions{}, "http://example.com/a", 308, "https://example.com:8443/a"},
+		{"ingress enabled", false, 308, "443", controller.HTTPSOptions{Redirect: &enabled}, "http://example.com/a", 308, "https://example.com/a"},
+		{"ingress disabled", true, 308, "443", controller.HTTPSOptions{Redirect: &disabled}, "http://example.com/a", 0, ""},
+		{"ingress status and port", true, 308, "443", controller.HTTPSOptions{RedirectStatus: 302, RedirectPort: "9443"}, "http://example.com/a", 302, "https://example.com:9443/a"},
+		{"acme challenge", true, 308, "443", controller.HTTPSOptions{}, "http://example.com" + acme.ChallengeURLPrefix + "token", 0, ""},
+	}
+	for _, c := range cases {
+		s := &Server{config: &config.Config{SSLRedirect: c.redirect, SSLRedirectStatus: c.status, SSLRedirectPort: c.port, HSTSMaxAge: 600}}
+		route := &controller.RoutePath{Options: &controller.IngressOptions{HTTPS: c.options}}
+		recorder := httptest.NewRecorder()
+
+		allowed := s.applyHTTPSPolicy(recorder, httptest.NewRequest("GET", c.url, nil), route)
+		if allowed != (c.expected == 0) {
+			t.Fatalf("unexpected output for %s %v", c.name, allowed)
+		}
+		if c.expected != 0 && recorder.Code != c.expected {
+			t.Fatalf("unexpected status for %s %v %v", c.name, recorder.Code, c.expected)
+		}
+		if actual := recorder.Header().Get("Location"); actual != c.location {
+			t.Fatalf("unexpected location for %s %s %s", c.name, actual, c.location)
+		}
+		// HSTS is only sent over HTTPS
+		if actual := recorder.Header().Get("Strict-Transport-Security"); actual != "" {
+			t.Fatalf("unexpected HSTS over HTTP for %s %s", c.name, actual)
+		}
+	}
+}
+
+func Test_applyHTTPSPolicy_HSTS(t *testing.T) {
+	enabled, disabled := true, false
+	maxAge, noMaxAge := 300, 0
+
+	cases := []struct {
+		name              string
+		maxAge            int
+		includeSubDomains bool
+		preload           bool
+		options           controller.HTTPSOptions
+		expected          string
+	}{
+		{"max age", 600, false, false, controller.HTTPSOptions{}, "max-age=600"},
+		{"disabled", 0, true, true, controller.HTTPSOptions{}, ""},
+		{"include subdomains", 600, true, false, controller.HTTPSOptions{}, "max-age=600; includeSubDomains"},
+		{"preload", 600, true, true, controller.HTTPSOptions{}, "max-age=600; includeSubDomains; preload"},
+		{"ingress max age", 600, false, false, controller.HTTPSOptions{HSTSMaxAge: &maxAge}, "max-age=300"},
+		{"ingress disabled", 600, true, false, controller.HTTPSOptions{HSTSMaxAge: &noMaxAge}, ""},
+		{"ingress enabled", 0, false, false, controller.HTTPSOptions{HSTSMaxAge: &maxAge}, "max-age=300"},
+		{"ingress flags", 600, true, false, controller.HTTPSOptions{HSTSIncludeSubDomains: &disabled, HSTSPreload: &enabled}, "max-age=600; preload"},
+	}
+	for _, c := range cases {
+		s := &Server{config: &config.Config{SSLRedirect: true, HSTSMaxAge: c.maxAge, HSTSIncludeSubDomains: c.includeSubDomains, HSTSPreload: c.preload}}
+		route := &controller.RoutePath{Options: &controller.IngressOptions{HTTPS: c.options}}
+		req := httptest.NewRequest("GET", "https://example.com/", nil)
+		req.TLS = &tls.ConnectionState{}
+		recorder := httptest.NewRecorder()
+
+		if !s.applyHTTPSPolicy(recorder, req, route) {
+			t.Fatalf("unexpected redirect for %s", c.name)
+		}
+		if actual := recorder.Header().Get("Strict-Transport-Security"); actual != c.expected {
+			t.Fatalf("unexpected output for %s %s %s", c.name, actual, c.expected)
+		}
+	}
+}
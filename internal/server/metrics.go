@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/elijahglover/inbound/internal/metrics"
+)
+
+const metricsURL = "/metrics"
+
+type requestInfoKey struct{}
+
+// requestInfo is filled in as a request is routed, used for instrumentation
+type requestInfo struct {
+	start     time.Time
+	host      string
+	path      string
+	service   string
+	upstream  string
+	ingress   string
+	accessLog bool
+	// grpcErrorStatus is the http equivalent of a proxy generated gRPC error, sent to the client as 200
+	grpcErrorStatus int
+}
+
+func withRequestInfo(req *http.Request, info *requestInfo) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), requestInfoKey{}, info))
+}
+
+func requestInfoFrom(req *http.Request) *requestInfo {
+	info, _ := req.Context().Value(requestInfoKey{}).(*requestInfo)
+	return info
+}
+
+// status of the response, gRPC errors are reported by their http equivalent
+func (info *requestInfo) status(recorder *responseRecorder) int {
+	if info != nil && info.grpcErrorStatus != 0 {
+		return info.grpcErrorStatus
+	}
+	return recorder.Status()
+}
+
+// serverMetrics exposed on the status server
+type serverMetrics struct {
+	registry      *metrics.Registry
+	requests      *metrics.Counter
+	duration      *metrics.Histogram
+	bytesIn       *metrics.Counter
+	bytesOut      *metrics.Counter
+	inFlight      *metrics.Gauge
+	tlsHandshakes *metrics.Counter
+	// Access log entries dropped as output could not keep up
+	accessLogDropped *metrics.Counter
+}
+
+func newServerMetrics() *serverMetrics {
+	registry := metrics.NewRegistry()
+	return &serverMetrics{
+		registry:         registry,
+		requests:         registry.NewCounter("inbound_requests_total", "Total requests by host, route path, upstream service and status class", "host", "path", "service", "class"),
+		duration:         registry.NewHistogram("inbound_request_duration_seconds", "Request latency in seconds", metrics.DefaultBuckets, "host", "path", "service"),
+		bytesIn:          registry.NewCounter("inbound_request_bytes_total", "Request body bytes received from clients", "host", "path", "service"),
+		bytesOut:         registry.NewCounter("inbound_response_bytes_total", "Response body bytes sent to clients", "host", "path", "service"),
+		inFlight:         registry.NewGauge("inbound_requests_in_flight", "Requests currently being handled"),
+		tlsHandshakes:    registry.NewCounter("inbound_tls_handshakes_total", "TLS handshakes by certificate used, host or fallback", "certificate"),
+		accessLogDropped: registry.NewCounter("inbound_access_log_dropped_total", "Access log entries dropped because output could not keep up"),
+	}
+}
+
+// observeRequest records a completed request, unmatched hosts are recorded with empty labels to bound cardinality
+func (m *serverMetrics) observeRequest(recorder *responseRecorder, body *countingReader, info *requestInfo) {
+	class := fmt.Sprintf("%vxx", info.status(recorder)/100)
+	m.requests.Inc(info.host, info.path, info.service, class)
+	m.duration.Observe(time.Since(info.start).Seconds(), info.host, info.path, info.service)
+	m.bytesIn.Add(float64(body.bytes), info.host, info.path, info.service)
+	m.bytesOut.Add(float64(recorder.bytes), info.host, info.path, info.service)
+}
+
+func (s *Server) handleMetricsRequest(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", metrics.ContentType)
+	w.WriteHeader(200)
+	s.metrics.registry.Write(w)
+}
+
+// countingReader counts request body bytes read by the proxy
+type countingReader struct {
+	io.ReadCloser
+	bytes int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.bytes += int64(n)
+	return n, err
+}
@@ -1,10 +1,15 @@
 package server
 
 import (
+	"github.com/elijahglover/inbound/internal/circuit"
 	"github.com/elijahglover/inbound/internal/controller"
+	"github.com/elijahglover/inbound/internal/healthcheck"
 )
 
 type healthcheckResponse struct {
-	Services []*controller.Service
-	Routes   []*controller.RouteTable
+	Services  []*controller.Service
+	Endpoints []*controller.Endpoints
+	Routes    []*controller.RouteTable
+	Health    []healthcheck.Status
+	Circuits  []circuit.Status
 }
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/elijahglover/inbound/internal/circuit"
+	"github.com/elijahglover/inbound/internal/controller"
+)
+
+// proxy forwards request to upstream, retrying other upstreams when the route allows it
+func (s *Server) proxy(w http.ResponseWriter, req *http.Request, route *controller.RoutePath, upstream string) {
+	// Overall request timeout covers every attempt
+	requestTimeout := s.config.RequestTimeout
+	if route.Options.Timeouts.Request > 0 {
+		requestTimeout = route.Options.Timeouts.Request
+	}
+	if requestTimeout > 0 {
+		ctx, cancel := context.WithTimeout(req.Context(), requestTimeout)
+		defer cancel()
+		req = req.WithContext(ctx)
+	}
+
+	retry := route.Options.Retry
+
+	// Requests with a body can't be replayed as the body has already been consumed
+	if retry == nil || !isIdempotent(req.Method) || req.ContentLength != 0 {
+		s.proxyAttempt(w, req, route, upstream)
+		return
+	}
+
+	s.proxyRetry(w, req, route, upstream, func(attempted []string) (string, error) {
+		return s.resolveUpstream(route, attempted, "")
+	})
+}
+
+// proxyRetry makes attempts against upstreams returned by next until a response is committed
+func (s *Server) proxyRetry(w http.ResponseWriter, req *http.Request, route *controller.RoutePath, upstream string, next func(attempted []string) (string, error)) {
+	retry := route.Options.Retry
+
+	s.retryBudget.beginRequest()
+	defer s.retryBudget.endRequest()
+
+	retryable := map[int]bool{}
+	for _, code := range retry.StatusCodes {
+		retryable[code] = true
+	}
+
+	attempted := []string{}
+	held := false
+	for attempt := 0; ; attempt++ {
+		attempted = append(attempted, upstream)
+
+		// Reserve budget for the next attempt before allowing this one to be held back
+		reserved := attempt < retry.Attempts && s.retryBudget.acquireRetry()
+		var attemptRetryable map[int]bool
+		if reserved {
+			attemptRetryable = retryable
+		}
+
+		writer := newRetryWriter(w, attemptRetryable)
+		attemptReq, done := req, func() {}
+		if retry.PerTryTimeout > 0 {
+			// Expired attempts surface as deadline errors, which the forwarder maps to 504
+			attempt := newAttemptContext(req.Context(), retry.PerTryTimeout)
+			writer.onCommit = attempt.stop
+			attemptReq, done = req.WithContext(attempt), attempt.done
+		}
+		s.proxyAttempt(writer, attemptReq, route, upstream)
+		done()
+
+		if held {
+			s.retryBudget.releaseRetry()
+		}
+		held = reserved
+
+		if !writer.discarded {
+			if held {
+				s.retryBudget.releaseRetry()
+			}
+			return
+		}
+
+		nextUpstream, err := next(attempted)
+		if err != nil {
+			s.retryBudget.releaseRetry()
+			s.writeError(w, req, 503, "Service unavailable")
+			return
+		}
+		s.logger.Infof("Retrying request to %s after failed attempt against %s", nextUpstream, upstream)
+		upstream = nextUpstream
+	}
+}
+
+// proxyAttempt forwards request to a single upstream and records the outcome against the upstream
+func (s *Server) proxyAttempt(w http.ResponseWriter, req *http.Request, route *controller.RoutePath, upstream string) {
+	// Another request may have claimed the half open trial since the upstream was resolved
+	var trial bool
+	if route.Options.CircuitBreaker != nil {
+		acquired, isTrial := s.breaker.Acquire(upstream)
+		if !acquired {
+			w.Header().Set("Retry-After", fmt.Sprintf("%v", int(s.breaker.RetryAfter([]string{upstream}).Seconds())))
+			s.writeError(w, req, 503, "Service unavailable")
+			return
+		}
+		trial = isTrial
+	}
+
+	// Track active requests for connection aware balancing
+	s.connections.Acquire(upstream)
+	defer s.connections.Release(upstream)
+
+	recorder := newResponseRecorder(w)
+	info := requestInfoFrom(req)
+	if info != nil {
+		info.upstream = upstream
+		info.grpcErrorStatus = 0
+	}
+
+	// Pin client to the upstream serving this attempt
+	s.setAffinityCookie(w, req, route, upstream)
+
+	// Proxy to lost
+	req.URL.Scheme = "http"
+	req.URL.Host = upstream
+	s.forwarder(route).ServeHTTP(recorder, req)
+
+	// Connection errors are surfaced by the forwarder as 5xx responses
+	if route.Options.CircuitBreaker == nil {
+		return
+	}
+	if info.status(recorder) >= 500 {
+		s.breaker.Failure(upstream, circuit.Settings{
+			Threshold: route.Options.CircuitBreaker.Threshold,
+			Cooldown:  route.Options.CircuitBreaker.Cooldown,
+		}, trial)
+		return
+	}
+	s.breaker.Success(upstream, trial)
+}
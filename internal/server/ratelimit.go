@@ -0,0 +1,44 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/elijahglover/inbound/internal/controller"
+)
+
+// allowRateLimit applies route rate limit, responds with 429 and returns false when over limit
+func (s *Server) allowRateLimit(w http.ResponseWriter, req *http.Request, route *controller.RoutePath) bool {
+	options := route.Options.RateLimit
+	if options == nil {
+		return true
+	}
+
+	key := route.Ingress + route.Path + "|"
+	switch options.Key {
+	case controller.RateLimitKeyRoute:
+	case controller.RateLimitKeyHeader:
+		key += req.Header.Get(options.Header)
+	default:
+		key += s.clientIP(req)
+	}
+
+	result := s.rateLimiter.Allow(key, options.RequestsPerSecond, options.Burst)
+	w.Header().Set("RateLimit-Limit", fmt.Sprintf("%v", result.Limit))
+	w.Header().Set("RateLimit-Remaining", fmt.Sprintf("%v", result.Remaining))
+	w.Header().Set("RateLimit-Reset", ceilSeconds(result.Reset))
+	if result.Allowed {
+		return true
+	}
+
+	s.logger.Verbosef("Rate limit exceeded for %s", key)
+	w.Header().Set("Retry-After", ceilSeconds(result.RetryAfter))
+	s.writeError(w, req, 429, "Too many requests")
+	return false
+}
+
+func ceilSeconds(duration time.Duration) string {
+	return fmt.Sprintf("%v", int(math.Ceil(duration.Seconds())))
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// writerDelegate passes flush, hijack and close notifications through to the wrapped writer
+// Wrappers embed it and only override what they need to intercept
+type writerDelegate struct {
+	http.ResponseWriter
+}
+
+func (d writerDelegate) Flush() {
+	if flusher, ok := d.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (d writerDelegate) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := d.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("Response writer does not support hijacking")
+	}
+	return hijacker.Hijack()
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (d writerDelegate) Unwrap() http.ResponseWriter {
+	return d.ResponseWriter
+}
+
+func (d writerDelegate) CloseNotify() <-chan bool {
+	if notifier, ok := d.ResponseWriter.(http.CloseNotifier); ok {
+		return notifier.CloseNotify()
+	}
+	return make(chan bool)
+}
+
+// responseRecorder captures status and size while passing through flush and hijack for streaming and websockets
+type responseRecorder struct {
+	writerDelegate
+	status int
+	bytes  int64
+}
+
+func newResponseRecorder(w http.ResponseWriter) *responseRecorder {
+	return &responseRecorder{writerDelegate: writerDelegate{w}}
+}
+
+// Status code written, defaults to 200 once body is written
+func (r *responseRecorder) Status() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 && !isInformational(status) {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+func (r *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := r.writerDelegate.Hijack()
+	// Hijacked connections are upgraded, record as switching protocols
+	if err == nil && r.status == 0 {
+		r.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// headerHookWriter calls hook with response headers before they are written
+type headerHookWriter struct {
+	writerDelegate
+	hook        func(http.Header)
+	wroteHeader bool
+}
+
+func newHeaderHookWriter(w http.ResponseWriter, hook func(http.Header)) *headerHookWriter {
+	return &headerHookWriter{writerDelegate: writerDelegate{w}, hook: hook}
+}
+
+func (h *headerHookWriter) WriteHeader(status int) {
+	if !h.wroteHeader && !isInformational(status) {
+		h.wroteHeader = true
+		h.hook(h.ResponseWriter.Header())
+	}
+	h.ResponseWriter.WriteHeader(status)
+}
+
+func (h *headerHookWriter) Write(b []byte) (int, error) {
+	if !h.wroteHeader {
+		h.WriteHeader(http.StatusOK)
+	}
+	return h.ResponseWriter.Write(b)
+}
+
+// isInformational 1xx responses precede the final response, switching protocols is final
+func isInformational(status int) bool {
+	return status >= 100 && status < 200 && status != http.StatusSwitchingProtocols
+}
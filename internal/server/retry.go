@@ -0,0 +1,185 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Always allow a few concurrent retries so low traffic routes can still retry
+const retryBudgetMinimum = 3
+
+// retryBudget limits active retries to a percentage of active requests
+type retryBudget struct {
+	percent  int
+	requests int
+	retries  int
+	lock     *sync.Mutex
+}
+
+func newRetryBudget(percent int) *retryBudget {
+	return &retryBudget{
+		percent: percent,
+		lock:    &sync.Mutex{},
+	}
+}
+
+func (b *retryBudget) beginRequest() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.requests++
+}
+
+func (b *retryBudget) endRequest() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.requests--
+}
+
+// acquireRetry returns false when the budget is exhausted
+func (b *retryBudget) acquireRetry() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	allowed := b.requests * b.percent / 100
+	if allowed < retryBudgetMinimum {
+		allowed = retryBudgetMinimum
+	}
+	if b.retries >= allowed {
+		return false
+	}
+	b.retries++
+	return true
+}
+
+func (b *retryBudget) releaseRetry() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.retries--
+}
+
+// isIdempotent methods are safe to send to another upstream
+func isIdempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE":
+		return true
+	}
+	return false
+}
+
+// attemptContext is cancelled when an attempt hasn't committed a response within the per-try timeout
+// Not a context deadline as streamed response bodies may outlive the timeout
+type attemptContext struct {
+	context.Context
+	cancel  context.CancelFunc
+	timer   *time.Timer
+	expired int32
+}
+
+func newAttemptContext(parent context.Context, timeout time.Duration) *attemptContext {
+	ctx, cancel := context.WithCancel(parent)
+	attempt := &attemptContext{Context: ctx, cancel: cancel}
+	attempt.timer = time.AfterFunc(timeout, func() {
+		atomic.StoreInt32(&attempt.expired, 1)
+		cancel()
+	})
+	return attempt
+}
+
+// Err reports expiry as a deadline so clients receive 504 or DEADLINE_EXCEEDED
+func (a *attemptContext) Err() error {
+	if atomic.LoadInt32(&a.expired) == 1 {
+		return context.DeadlineExceeded
+	}
+	return a.Context.Err()
+}
+
+// stop the per-try timeout once the response is committed
+func (a *attemptContext) stop() {
+	a.timer.Stop()
+}
+
+// done releases the attempt once it has been proxied
+func (a *attemptContext) done() {
+	a.timer.Stop()
+	a.cancel()
+}
+
+// retryWriter holds back retryable responses so another attempt can be made
+// Headers are buffered until the response is committed to the client
+type retryWriter struct {
+	writerDelegate
+	header    http.Header
+	retryable map[int]bool
+	discarded bool
+	committed bool
+	// onCommit is called once the response is committed, stops the per-try timeout
+	onCommit func()
+}
+
+func newRetryWriter(w http.ResponseWriter, retryable map[int]bool) *retryWriter {
+	return &retryWriter{
+		writerDelegate: writerDelegate{w},
+		header:         http.Header{},
+		retryable:      retryable,
+	}
+}
+
+func (r *retryWriter) Header() http.Header {
+	if r.committed {
+		return r.ResponseWriter.Header()
+	}
+	return r.header
+}
+
+func (r *retryWriter) WriteHeader(status int) {
+	// Informational responses are dropped, the attempt may still be retried
+	if r.committed || r.discarded || isInformational(status) {
+		return
+	}
+	if r.retryable[status] {
+		r.discarded = true
+		return
+	}
+	r.commit()
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *retryWriter) Write(b []byte) (int, error) {
+	if r.discarded {
+		return len(b), nil
+	}
+	if !r.committed {
+		r.WriteHeader(http.StatusOK)
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *retryWriter) Flush() {
+	if !r.committed {
+		return
+	}
+	r.writerDelegate.Flush()
+}
+
+func (r *retryWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	r.commit()
+	return r.writerDelegate.Hijack()
+}
+
+func (r *retryWriter) commit() {
+	if r.committed {
+		return
+	}
+	r.committed = true
+	if r.onCommit != nil {
+		r.onCommit()
+	}
+	for key, values := range r.header {
+		r.ResponseWriter.Header()[key] = values
+	}
+}
@@ -0,0 +1,228 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elijahglover/inbound/internal/balancer"
+	"github.com/elijahglover/inbound/internal/circuit"
+	"github.com/elijahglover/inbound/internal/config"
+	"github.com/elijahglover/inbound/internal/controller"
+	"github.com/elijahglover/inbound/internal/logger"
+)
+
+func Test_retryWriter(t *testing.T) {
+	retryable := map[int]bool{502: true, 503: true}
+
+	cases := []struct {
+		retryable map[int]bool
+		status    int
+		discarded bool
+		expected  int
+	}{
+		{retryable, 503, true, 0},
+		{retryable, 500, false, 500},
+		{retryable, 200, false, 200},
+		{nil, 503, false, 503},
+		{retryable, 0, false, 200},
+	}
+	for _, c := range cases {
+		recorder := httptest.NewRecorder()
+		writer := newRetryWriter(recorder, c.retryable)
+		writer.Header().Set("X-Upstream", "a")
+		if c.status > 0 {
+			writer.WriteHeader(c.status)
+		}
+		writer.Write([]byte("body"))
+
+		if writer.discarded != c.discarded {
+			t.Fatalf("unexpected discarded for %v %v %v", c.status, writer.discarded, c.discarded)
+		}
+		if c.discarded {
+			if recorder.Header().Get("X-Upstream") != "" || recorder.Body.Len() > 0 {
+				t.Fatalf("discarded response leaked to client for %v", c.status)
+			}
+			continue
+		}
+		if recorder.Code != c.expected {
+			t.Fatalf("unexpected status %v %v", recorder.Code, c.expected)
+		}
+		if recorder.Header().Get("X-Upstream") != "a" || recorder.Body.String() != "body" {
+			t.Fatalf("committed response missing headers or body for %v", c.status)
+		}
+	}
+}
+
+func Test_retryWriter_Ignores_Status_After_Commit(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	writer := newRetryWriter(recorder, map[int]bool{503: true})
+	writer.WriteHeader(200)
+	writer.WriteHeader(503)
+	writer.Header().Set("X-Late", "a")
+
+	if writer.discarded || recorder.Code != 200 {
+		t.Fatalf("unexpected status after commit %v", recorder.Code)
+	}
+	if recorder.Header().Get("X-Late") != "a" {
+		t.Fatalf("headers after commit not passed through")
+	}
+}
+
+func Test_retryWriter_Drops_Informational(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	writer := newRetryWriter(recorder, map[int]bool{503: true})
+	writer.WriteHeader(103)
+	writer.WriteHeader(503)
+
+	if !writer.discarded || writer.committed {
+		t.Fatalf("informational response committed attempt")
+	}
+}
+
+func Test_retryBudget(t *testing.T) {
+	cases := []struct {
+		percent  int
+		requests int
+		expected int
+	}{
+		{20, 1, 3},
+		{20, 10, 3},
+		{20, 20, 4},
+		{50, 10, 5},
+		{0, 100, 3},
+	}
+	for _, c := range cases {
+		budget := newRetryBudget(c.percent)
+		for i := 0; i < c.requests; i++ {
+			budget.beginRequest()
+		}
+
+		acquired := 0
+		for budget.acquireRetry() {
+			acquired++
+		}
+		if acquired != c.expected {
+			t.Fatalf("unexpected retries for %v%% of %v %v %v", c.percent, c.requests, acquired, c.expected)
+		}
+
+		budget.releaseRetry()
+		if !budget.acquireRetry() {
+			t.Fatalf("released retry not available again")
+		}
+	}
+}
+
+func Test_proxyRetry_PerTryTimeout(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		select {
+		case <-req.Context().Done():
+		case <-time.After(time.Second):
+		}
+		w.Write([]byte("slow"))
+	}))
+	defer slow.Close()
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("fast"))
+	}))
+	defer fast.Close()
+
+	s := &Server{
+		logger:         logger.NewNull(),
+		config:         &config.Config{},
+		controller:     &fakeController{},
+		forwarders:     map[forwarderSettings]http.Handler{},
+		forwardersLock: &sync.Mutex{},
+		connections:    balancer.NewConnections(),
+		breaker:        circuit.New(logger.NewNull()),
+		retryBudget:    newRetryBudget(20),
+	}
+	route := &controller.RoutePath{
+		Path: "/",
+		Options: &controller.IngressOptions{
+			Retry: &controller.RetryOptions{
+				Attempts:      1,
+				PerTryTimeout: 50 * time.Millisecond,
+				StatusCodes:   []int{502, 503, 504},
+			},
+		},
+	}
+
+	slowHost := strings.TrimPrefix(slow.URL, "http://")
+	fastHost := strings.TrimPrefix(fast.URL, "http://")
+	attempts := []string{}
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "http://example.com/", nil)
+	s.proxyRetry(recorder, req, route, slowHost, func(attempted []string) (string, error) {
+		attempts = append(attempts, attempted...)
+		return fastHost, nil
+	})
+
+	if recorder.Code != 200 || recorder.Body.String() != "fast" {
+		t.Fatalf("unexpected response %v %s", recorder.Code, recorder.Body.String())
+	}
+	if len(attempts) != 1 || attempts[0] != slowHost {
+		t.Fatalf("unexpected attempts %v", attempts)
+	}
+
+	// Without attempts remaining the expired try is reported as a gateway timeout
+	route.Options.Retry.Attempts = 0
+	recorder = httptest.NewRecorder()
+	s.proxyRetry(recorder, req, route, slowHost, func(attempted []string) (string, error) {
+		t.Fatalf("unexpected retry")
+		return "", nil
+	})
+	if recorder.Code != 504 {
+		t.Fatalf("unexpected status %v", recorder.Code)
+	}
+}
+
+func Test_proxyRetry_PerTryTimeout_Streamed_Body(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("first "))
+		w.(http.Flusher).Flush()
+		select {
+		case <-req.Context().Done():
+			return
+		case <-time.After(200 * time.Millisecond):
+		}
+		w.Write([]byte("second"))
+	}))
+	defer upstream.Close()
+
+	s := &Server{
+		logger:         logger.NewNull(),
+		config:         &config.Config{},
+		controller:     &fakeController{},
+		forwarders:     map[forwarderSettings]http.Handler{},
+		forwardersLock: &sync.Mutex{},
+		connections:    balancer.NewConnections(),
+		breaker:        circuit.New(logger.NewNull()),
+		retryBudget:    newRetryBudget(20),
+	}
+	route := &controller.RoutePath{
+		Path: "/",
+		Options: &controller.IngressOptions{
+			Retry: &controller.RetryOptions{
+				Attempts:      1,
+				PerTryTimeout: 50 * time.Millisecond,
+				StatusCodes:   []int{502, 503, 504},
+			},
+		},
+	}
+
+	// Per-try timeout stops once headers are committed, the body outlives it
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "http://example.com/", nil)
+	s.proxyRetry(recorder, req, route, strings.TrimPrefix(upstream.URL, "http://"), func(attempted []string) (string, error) {
+		t.Fatalf("unexpected retry")
+		return "", nil
+	})
+
+	if recorder.Code != 200 || recorder.Body.String() != "first second" {
+		t.Fatalf("unexpected response %v %s", recorder.Code, recorder.Body.String())
+	}
+}
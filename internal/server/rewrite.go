@@ -0,0 +1,56 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/elijahglover/inbound/internal/controller"
+)
+
+// rewriteRequest returns a copy of request with the route rewrite applied to the path
+func (s *Server) rewriteRequest(req *http.Request, route *controller.RoutePath) *http.Request {
+	// Only set by the proxy, clients can't supply their own
+	req.Header.Del("X-Original-URI")
+
+	options := route.Options.Rewrite
+	if options == nil {
+		return req
+	}
+
+	path := req.URL.Path
+	remainder := route.Remainder(path)
+	switch {
+	case options.Regex != nil:
+		if !options.Regex.MatchString(path) {
+			return req
+		}
+		path = options.Regex.ReplaceAllString(path, options.Target)
+	case options.StripPrefix:
+		path = joinPath("/", remainder)
+	default:
+		path = joinPath(options.Prefix, remainder)
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	// Deep copy so the original url and headers are kept for retries and logging
+	rewritten := req.Clone(req.Context())
+	url := rewritten.URL
+	url.Path = path
+	url.RawPath = ""
+	rewritten.Header.Set("X-Original-URI", req.URL.RequestURI())
+	// Forwarder builds the upstream url from RequestURI when present
+	rewritten.RequestURI = url.RequestURI()
+
+	s.logger.Verbosef("Rewrote path %s to %s", req.URL.Path, path)
+	return rewritten
+}
+
+// joinPath joins prefix and remainder with a single slash
+func joinPath(prefix string, remainder string) string {
+	if remainder == "" {
+		return prefix
+	}
+	return strings.TrimSuffix(prefix, "/") + "/" + strings.TrimPrefix(remainder, "/")
+}
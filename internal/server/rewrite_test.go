@@ -0,0 +1,78 @@
+package server
+
+import (
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/elijahglover/inbound/internal/controller"
+	"github.com/elijahglover/inbound/internal/logger"
+)
+
+func Test_rewriteRequest(t *testing.T) {
+	s := &Server{logger: logger.NewNull()}
+	strip := &controller.RewriteOptions{StripPrefix: true}
+	prefix := &controller.RewriteOptions{Prefix: "/v1/"}
+	regex := &controller.RewriteOptions{Regex: regexp.MustCompile("^/api/(.*)$"), Target: "/internal/$1"}
+
+	cases := []struct {
+		match     string
+		routePath string
+		rewrite   *controller.RewriteOptions
+		path      string
+		expected  string
+	}{
+		{controller.MatchPrefix, "/api", strip, "/api/users", "/users"},
+		{controller.MatchPrefix, "/api/", strip, "/api/users", "/users"},
+		{controller.MatchPrefix, "/api", strip, "/api", "/"},
+		{controller.MatchPrefix, "/api/", strip, "/api/", "/"},
+		{controller.MatchPrefix, "/", strip, "/users", "/users"},
+		{controller.MatchPrefix, "/api", prefix, "/api/users", "/v1/users"},
+		{controller.MatchPrefix, "/api/", prefix, "/api/users/", "/v1/users/"},
+		{controller.MatchPrefix, "/api", prefix, "/api", "/v1/"},
+		{controller.MatchPrefix, "/api", regex, "/api/users", "/internal/users"},
+		{controller.MatchPrefix, "/api", regex, "/other", "/other"},
+		{controller.MatchPrefix, "/api", nil, "/api/users", "/api/users"},
+		{controller.MatchExact, "/api/users", strip, "/api/users", "/"},
+		{controller.MatchExact, "/api/users", prefix, "/api/users", "/v1/"},
+		{controller.MatchRegex, "/api/v[0-9]+", strip, "/api/v2/users", "/users"},
+		{controller.MatchRegex, "/api/v[0-9]+", strip, "/api/v10", "/"},
+		{controller.MatchRegex, "/api/v[0-9]+/", prefix, "/api/v2/users", "/v1/users"},
+		{controller.MatchRegex, "/api/v[0-9]+/", regex, "/api/v2/users", "/internal/v2/users"},
+	}
+	for _, c := range cases {
+		route := &controller.RoutePath{
+			Path:    c.routePath,
+			Match:   c.match,
+			Options: &controller.IngressOptions{Rewrite: c.rewrite},
+		}
+		if c.match == controller.MatchRegex {
+			route.Regex = regexp.MustCompile("^(?:" + c.routePath + ")")
+		}
+
+		req := httptest.NewRequest("GET", "http://example.com"+c.path+"?q=1", nil)
+		req.Header.Set("X-Original-URI", "/spoofed")
+		rewritten := s.rewriteRequest(req, route)
+
+		if rewritten.URL.Path != c.expected {
+			t.Fatalf("unexpected output for %s %s %s %s", c.routePath, c.path, rewritten.URL.Path, c.expected)
+		}
+		if rewritten.RequestURI != c.expected+"?q=1" && rewritten != req {
+			t.Fatalf("unexpected request uri %s", rewritten.RequestURI)
+		}
+		// Client supplied original uri is never passed upstream
+		originalURI := ""
+		if rewritten != req {
+			originalURI = c.path + "?q=1"
+		}
+		if actual := rewritten.Header.Get("X-Original-URI"); actual != originalURI {
+			t.Fatalf("unexpected original uri for %s %s %s", c.path, actual, originalURI)
+		}
+		if req.URL.Path != c.path {
+			t.Fatalf("original request modified %s", req.URL.Path)
+		}
+		if rewritten != req && req.Header.Get("X-Original-URI") != "" {
+			t.Fatalf("original request headers modified %s", req.Header.Get("X-Original-URI"))
+		}
+	}
+}
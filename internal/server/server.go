@@ -10,40 +10,100 @@ import (
 	stdlog "log"
 	"net/http"
 	"net/url"
-	"strings"
+	"os"
+	"sync"
 	"time"
 
+	"github.com/elijahglover/inbound/internal/accesslog"
+	"github.com/elijahglover/inbound/internal/acme"
+	"github.com/elijahglover/inbound/internal/balancer"
+	"github.com/elijahglover/inbound/internal/circuit"
 	"github.com/elijahglover/inbound/internal/config"
 	"github.com/elijahglover/inbound/internal/controller"
+	"github.com/elijahglover/inbound/internal/errorpages"
+	"github.com/elijahglover/inbound/internal/healthcheck"
 	"github.com/elijahglover/inbound/internal/helpers"
 	"github.com/elijahglover/inbound/internal/logger"
-	"github.com/vulcand/oxy/forward"
+	"github.com/elijahglover/inbound/internal/ratelimit"
 )
 
+// controllerState is the routing state read from the controller
+type controllerState interface {
+	GetCertificate(hostname string) *tls.Certificate
+	GetCredentials(secret string) map[string]string
+	GetErrorPages() *errorpages.Pages
+	GetService(service string) *controller.Service
+	GetEndpoints(service string, port int32) []string
+	GetAllEndpoints() []*controller.Endpoints
+	GetRouteTable(host string) *controller.RouteTable
+	GetDefaultRoute() *controller.RoutePath
+	GetRouteTables() []*controller.RouteTable
+	GetServices() []*controller.Service
+}
+
 // Server component
 type Server struct {
 	logger     logger.Logger
 	config     *config.Config
-	controller *controller.Controller
-	httpLogger *log.Logger        // Used to mute stdout
-	fwd        *forward.Forwarder // Middleware to proxy websockets and pass host headers
+	controller controllerState
+	httpLogger *log.Logger // Used to mute stdout
+	// Forwarders keyed by transport settings, middleware to proxy websockets and pass host headers
+	forwarders     map[forwarderSettings]http.Handler
+	forwardersLock *sync.Mutex
+	// Balancers keyed by algorithm name, share active connection tracking
+	balancers   map[string]balancer.Balancer
+	connections *balancer.Connections
+	// Active upstream health checks
+	healthChecker *healthcheck.Checker
+	// Passive failure detection of upstream targets
+	breaker *circuit.Breaker
+	// Limits retries across all routes
+	retryBudget *retryBudget
+	// Certificate issuance, nil when disabled
+	acme *acme.Manager
+	// Prometheus metrics
+	metrics *serverMetrics
+	// Access log, nil when disabled
+	accessLog *accesslog.Logger
+	// Token buckets for rate limited routes
+	rateLimiter *ratelimit.Limiter
+	// Client for forward auth requests, redirects are returned to the client
+	authClient *http.Client
+	// Signing key for session affinity cookies
+	affinityKey []byte
 }
 
 // New server component
-func New(logger logger.Logger, config *config.Config, controller *controller.Controller) *Server {
+func New(logger logger.Logger, config *config.Config, controller *controller.Controller, acme *acme.Manager) *Server {
 	server := &Server{
 		controller: controller,
 		logger:     logger,
 		config:     config,
+		acme:       acme,
 	}
 
-	fwd, _ := forward.New(
-		forward.Stream(true),
-		forward.StreamingFlushInterval(100*time.Millisecond),
-		forward.PassHostHeader(true),
-		forward.Rewriter(&forward.HeaderRewriter{TrustForwardHeader: false}), //Don't trust anything up stream as this is internet facing
-	)
-	server.fwd = fwd
+	server.connections = balancer.NewConnections()
+	server.balancers = map[string]balancer.Balancer{}
+	for _, algorithm := range []string{balancer.RoundRobin, balancer.LeastConnections, balancer.RandomTwoChoices} {
+		server.balancers[algorithm] = balancer.New(algorithm, server.connections)
+	}
+
+	server.forwarders = map[forwarderSettings]http.Handler{}
+	server.forwardersLock = &sync.Mutex{}
+	server.healthChecker = healthcheck.New(logger)
+	server.breaker = circuit.New(logger)
+	server.retryBudget = newRetryBudget(config.RetryBudgetPercent)
+	server.metrics = newServerMetrics()
+	server.rateLimiter = ratelimit.New()
+	server.affinityKey = newAffinityKey(config.AffinitySecret)
+	server.authClient = &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	if config.AccessLogFormat != "off" {
+		server.accessLog = accesslog.New(config.AccessLogFormat, config.AccessLogSampleRate, os.Stdout)
+	}
 	server.httpLogger = stdlog.New(ioutil.Discard, "", 0)
 	return server
 }
@@ -59,6 +119,9 @@ func (s *Server) Start(ctx context.Context) error {
 		s.logger.Info("Generated fallback TLS certificate")
 	}
 
+	go s.syncHealthChecks(ctx)
+	go s.rateLimiter.Run(ctx)
+
 	//Start HTTPS Server
 	go func() {
 		tlsConfig := &tls.Config{
@@ -80,24 +143,32 @@ func (s *Server) Start(ctx context.Context) error {
 			GetCertificate: s.resolveCertificate,
 		}
 		srv := &http.Server{
-			Addr:         ":" + s.config.HTTPSPort,
-			Handler:      http.HandlerFunc(s.handleRequest),
-			TLSConfig:    tlsConfig,
-			TLSNextProto: make(map[string]func(*http.Server, *tls.Conn, http.Handler), 0), // Forward Secrecy
-			ErrorLog:     s.httpLogger,
+			Addr:              ":" + s.config.HTTPSPort,
+			Handler:           http.HandlerFunc(s.handleRequest),
+			TLSConfig:         tlsConfig,
+			ReadHeaderTimeout: s.config.ReadHeaderTimeout,
+			ReadTimeout:       s.config.ReadTimeout,
+			WriteTimeout:      s.config.WriteTimeout,
+			IdleTimeout:       s.config.IdleTimeout,
+			ErrorLog:          s.httpLogger,
 		}
-		s.logger.Infof("Listening on HTTPS 0.0.0.0:%s", s.config.HTTPSPort)
+		if !s.config.HTTP2 {
+			srv.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler), 0) // Disables HTTP/2
+		}
+		s.logger.Infof("Listening on HTTPS 0.0.0.0:%s (HTTP/2 %v)", s.config.HTTPSPort, s.config.HTTP2)
 		log.Fatal(srv.ListenAndServeTLS("", ""))
 	}()
 
 	go func() {
 		//Start HTTP Server
 		srv := &http.Server{
-			Addr:         ":" + s.config.HTTPPort,
-			Handler:      http.HandlerFunc(s.handleRequest),
-			ReadTimeout:  5 * time.Second,
-			WriteTimeout: 10 * time.Second,
-			ErrorLog:     s.httpLogger,
+			Addr:              ":" + s.config.HTTPPort,
+			Handler:           http.HandlerFunc(s.handleRequest),
+			ReadHeaderTimeout: s.config.ReadHeaderTimeout,
+			ReadTimeout:       s.config.ReadTimeout,
+			WriteTimeout:      s.config.WriteTimeout,
+			IdleTimeout:       s.config.IdleTimeout,
+			ErrorLog:          s.httpLogger,
 		}
 		s.logger.Infof("Listening on HTTP 0.0.0.0:%s", s.config.HTTPPort)
 		log.Fatal(srv.ListenAndServe())
@@ -118,66 +189,212 @@ func (s *Server) Start(ctx context.Context) error {
 func (s *Server) resolveCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
 	cert := s.controller.GetCertificate(hello.ServerName)
 	if cert == nil {
+		s.metrics.tlsHandshakes.Inc("fallback")
 		s.logger.Warningf("Missing certificate for host %s, using fallback", hello.ServerName)
 		return fallbackCertificate, nil
 	}
+	s.metrics.tlsHandshakes.Inc("host")
 	return cert, nil
 }
 
 func (s *Server) handleRequest(w http.ResponseWriter, req *http.Request) {
+	info := &requestInfo{start: time.Now(), accessLog: true}
+	recorder := newResponseRecorder(w)
+	body := &countingReader{ReadCloser: req.Body}
+	req.Body = body
+
+	s.metrics.inFlight.Inc()
+	defer func() {
+		s.metrics.inFlight.Dec()
+		s.metrics.observeRequest(recorder, body, info)
+		if s.accessLog != nil && info.accessLog {
+			if !s.accessLog.Log(s.accessLogEntry(req, recorder, info)) {
+				s.metrics.accessLogDropped.Inc()
+			}
+		}
+	}()
+
+	s.routeRequest(recorder, withRequestInfo(req, info), info)
+}
+
+func (s *Server) routeRequest(w http.ResponseWriter, req *http.Request, info *requestInfo) {
+	// Answer ACME HTTP-01 challenges for certificates being issued
+	if s.acme != nil && s.acme.HandleChallenge(w, req) {
+		return
+	}
+
 	host := helpers.ExtractHostname(req.Host)
 	routeTable := s.controller.GetRouteTable(host)
 
 	//No route table found - no defined contract or the controller isn't ready
 	if routeTable == nil {
-		w.WriteHeader(503) // Service Unavailable
-		w.Write([]byte("Service unavailable\n"))
+		route := s.controller.GetDefaultRoute()
+		if route == nil {
+			s.writeError(w, req, 503, "Service unavailable")
+			return
+		}
+		s.serveRoute(w, req, info, route)
+		return
+	}
+	info.host = routeTable.Host
+
+	route := s.matchRoute(routeTable.Paths, req.URL)
+	if route == nil {
+		route = routeTable.Default
+	}
+	if route == nil {
+		route = s.controller.GetDefaultRoute()
+	}
+	if route == nil {
+		s.writeError(w, req, 404, "Unable to resolve service for path")
+		return
+	}
+	s.serveRoute(w, req, info, route)
+}
+
+// serveRoute applies route policies and proxies to an upstream of the route
+func (s *Server) serveRoute(w http.ResponseWriter, req *http.Request, info *requestInfo, route *controller.RoutePath) {
+	info.path = route.Path
+	info.service = route.ServiceName
+	info.ingress = route.Ingress
+	info.accessLog = route.Options.AccessLog
+
+	if !s.applyHTTPSPolicy(w, req, route) {
+		return
+	}
+
+	if !s.allowSourceRange(w, req, route) {
+		return
+	}
+
+	// Preflight requests are answered before authentication as browsers send them without credentials
+	w, ok := s.applyCORS(w, req, route)
+	if !ok {
 		return
 	}
 
-	// Upgrade requests to https if http and not matching acme challenge
-	if req.URL.Scheme == "http" && !strings.HasPrefix(req.URL.Path, acmeChallengeURLPrefix) {
-		req.URL.Scheme = "https"
-		w.Header().Set("Location", req.URL.String())
-		w.WriteHeader(301)
+	if !s.allowRateLimit(w, req, route) {
 		return
 	}
 
-	//Add HSTS
-	w.Header().Add("Strict-Transport-Security", "max-age=63072000; includeSubDomains")
+	if !s.allowBasicAuth(w, req, route) {
+		return
+	}
 
-	upstreamService := s.resolveUpstream(routeTable.Paths, req.URL)
-	if upstreamService == "" {
-		w.WriteHeader(404)
-		w.Write([]byte("Unable to resolve service for path\n"))
+	if !s.allowForwardAuth(w, req, route) {
 		return
 	}
 
-	s.logger.Infof("Routing request to %s", upstreamService)
+	// Split traffic between primary and canary services
+	route = s.selectCanary(req, route)
+	info.service = route.ServiceName
+	info.ingress = route.Ingress
+
+	upstreamService, err := s.resolveUpstream(route, nil, s.affinityTarget(req, route))
+	if err != nil {
+		if circuitErr, ok := err.(*circuitOpenError); ok {
+			w.Header().Set("Retry-After", fmt.Sprintf("%v", int(circuitErr.retryAfter.Seconds())))
+		}
+		s.writeError(w, req, 503, "Service unavailable")
+		return
+	}
+
+	// Compress upstream responses negotiated from Accept-Encoding
+	if compressor := s.compressResponse(w, req, route); compressor != nil {
+		defer compressor.Close()
+		w = compressor
+	}
+
+	// Replace upstream failures with error pages
+	if s.interceptErrors(req, route) {
+		w = newErrorPageWriter(s, w, req)
+	}
 
-	// Proxy to lost
-	req.URL.Scheme = "http"
-	req.URL.Host = upstreamService
-	s.fwd.ServeHTTP(w, req)
+	s.logger.Verbosef("Routing request to %s", upstreamService)
+	s.proxy(w, s.rewriteRequest(req, route), route, upstreamService)
 }
 
-func (s *Server) resolveUpstream(routes []controller.RoutePath, url *url.URL) string {
+func (s *Server) matchRoute(routes []controller.RoutePath, url *url.URL) *controller.RoutePath {
 	matchedPath := url.Path
 	for _, route := range routes {
-		if strings.HasPrefix(matchedPath, route.Path) {
-			service := s.controller.GetService(route.ServiceName)
-			if service == nil {
-				s.logger.Infof("Unable to find service %s to match route %s", route.ServiceName, route.Path)
-				return ""
+		if route.Matches(matchedPath) {
+			s.logger.Verbosef("Matched path %s to route %s", matchedPath, route.Path)
+			return &route
+		}
+	}
+	return nil
+}
+
+// resolveUpstream selects a target for route, excluded targets are only used when nothing else is available
+// pinned target from session affinity is used while it is available
+func (s *Server) resolveUpstream(route *controller.RoutePath, exclude []string, pinned string) (string, error) {
+	service := s.controller.GetService(route.ServiceName)
+	if service == nil {
+		s.logger.Infof("Unable to find service %s to match route %s", route.ServiceName, route.Path)
+		return "", errNoUpstream
+	}
+
+	// Fallback to cluster ip until endpoints have been discovered
+	targets := s.controller.GetEndpoints(route.ServiceName, route.ServicePort)
+	if targets == nil {
+		s.logger.Verbosef("No endpoints discovered for %s, using %s:%v", route.ServiceName, service.ClusterIP, route.ServicePort)
+		targets = []string{fmt.Sprintf("%s:%v", service.ClusterIP, route.ServicePort)}
+	}
+
+	// Remove targets failing active health checks
+	healthy := make([]string, 0, len(targets))
+	for _, target := range targets {
+		if s.healthChecker.Healthy(target) {
+			healthy = append(healthy, target)
+		}
+	}
+	if len(healthy) == 0 {
+		s.logger.Infof("No healthy endpoints for service %s port %v", route.ServiceName, route.ServicePort)
+		return "", errNoUpstream
+	}
+
+	// Remove targets with open circuits
+	available := make([]string, 0, len(healthy))
+	for _, target := range healthy {
+		if s.breaker.Available(target) {
+			available = append(available, target)
+		}
+	}
+	if len(available) == 0 {
+		s.logger.Infof("All circuits open for service %s port %v", route.ServiceName, route.ServicePort)
+		return "", &circuitOpenError{retryAfter: s.breaker.RetryAfter(healthy)}
+	}
+
+	// Prefer targets not already attempted
+	if len(exclude) > 0 {
+		remaining := make([]string, 0, len(available))
+		for _, target := range available {
+			if !helpers.ContainsString(exclude, target) {
+				remaining = append(remaining, target)
 			}
-			s.logger.Verbosef("Matched path %s to service %s:%v", matchedPath, service.ClusterIP, route.ServicePort)
-			return fmt.Sprintf("%s:%v", service.ClusterIP, route.ServicePort)
 		}
+		if len(remaining) > 0 {
+			available = remaining
+		}
+	}
+
+	if pinned != "" && helpers.ContainsString(available, pinned) {
+		return pinned, nil
+	}
+
+	b, ok := s.balancers[route.Options.LoadBalance]
+	if !ok {
+		b = s.balancers[balancer.RoundRobin]
 	}
-	return ""
+	return b.Next(route.ServiceName, available), nil
 }
 
 func (s *Server) handleStatusRequest(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == metricsURL {
+		s.handleMetricsRequest(w, req)
+		return
+	}
+
 	if req.URL.Path == healthcheckURL {
 		w.WriteHeader(200)
 		w.Write([]byte("Healthy\n"))
@@ -185,11 +402,15 @@ func (s *Server) handleStatusRequest(w http.ResponseWriter, req *http.Request) {
 	}
 
 	services := s.controller.GetServices()
+	endpoints := s.controller.GetAllEndpoints()
 	routes := s.controller.GetRouteTables()
 
 	response := healthcheckResponse{
-		Services: services,
-		Routes:   routes,
+		Services:  services,
+		Endpoints: endpoints,
+		Routes:    routes,
+		Health:    s.healthChecker.Statuses(),
+		Circuits:  s.breaker.Statuses(),
 	}
 
 	responseRaw, err := json.MarshalIndent(response, "", "  ")
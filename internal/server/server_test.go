@@ -0,0 +1,134 @@
+package server
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/elijahglover/inbound/internal/config"
+	"github.com/elijahglover/inbound/internal/controller"
+	"github.com/elijahglover/inbound/internal/errorpages"
+	"github.com/elijahglover/inbound/internal/logger"
+)
+
+// newTestServer builds a server reading routing state from state
+func newTestServer(config *config.Config, state *fakeController) *Server {
+	config.AccessLogFormat = "off"
+	s := New(logger.NewNull(), config, nil, nil)
+	s.controller = state
+	return s
+}
+
+// fakeController serves fixed routing state
+type fakeController struct {
+	errorPages *errorpages.Pages
+	// Services - key = service name
+	services map[string]*controller.Service
+	// Endpoints - key = service name, ports are ignored
+	endpoints map[string][]string
+	// Route tables - key = host, wildcard hosts are matched like the controller
+	routeTables  map[string]*controller.RouteTable
+	defaultRoute *controller.RoutePath
+}
+
+func (c *fakeController) GetCertificate(hostname string) *tls.Certificate {
+	return nil
+}
+
+func (c *fakeController) GetCredentials(secret string) map[string]string {
+	return nil
+}
+
+func (c *fakeController) GetErrorPages() *errorpages.Pages {
+	return c.errorPages
+}
+
+func (c *fakeController) GetService(service string) *controller.Service {
+	return c.services[service]
+}
+
+func (c *fakeController) GetEndpoints(service string, port int32) []string {
+	return c.endpoints[service]
+}
+
+func (c *fakeController) GetAllEndpoints() []*controller.Endpoints {
+	return nil
+}
+
+func (c *fakeController) GetRouteTable(host string) *controller.RouteTable {
+	if routeTable, ok := c.routeTables[host]; ok {
+		return routeTable
+	}
+	if index := strings.Index(host, "."); index > 0 {
+		return c.routeTables["*"+host[index:]]
+	}
+	return nil
+}
+
+func (c *fakeController) GetDefaultRoute() *controller.RoutePath {
+	return c.defaultRoute
+}
+
+func (c *fakeController) GetRouteTables() []*controller.RouteTable {
+	return nil
+}
+
+func (c *fakeController) GetServices() []*controller.Service {
+	return nil
+}
+
+func Test_routeRequest_Default_Backend(t *testing.T) {
+	// Each upstream responds with its service name
+	state := &fakeController{services: map[string]*controller.Service{}, endpoints: map[string][]string{}}
+	for _, service := range []string{"app", "wildcard", "exact", "backend", "catchall"} {
+		name := service
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Write([]byte(name))
+		}))
+		defer upstream.Close()
+		state.services[name] = &controller.Service{ServiceName: name}
+		state.endpoints[name] = []string{strings.TrimPrefix(upstream.URL, "http://")}
+	}
+	route := func(service string, path string) *controller.RoutePath {
+		return &controller.RoutePath{Ingress: service, Path: path, ServiceName: service, ServicePort: 80, Options: &controller.IngressOptions{}}
+	}
+	state.routeTables = map[string]*controller.RouteTable{
+		"app.example.com":     {Host: "app.example.com", Paths: []controller.RoutePath{*route("app", "/api")}},
+		"*.example.com":       {Host: "*.example.com", Paths: []controller.RoutePath{*route("wildcard", "/")}},
+		"exact.example.com":   {Host: "exact.example.com", Paths: []controller.RoutePath{*route("exact", "/")}},
+		"backend.example.com": {Host: "backend.example.com", Paths: []controller.RoutePath{*route("app", "/api")}, Default: route("backend", "")},
+	}
+	s := newTestServer(&config.Config{}, state)
+
+	cases := []struct {
+		url          string
+		defaultRoute *controller.RoutePath
+		status       int
+		expected     string
+	}{
+		{"http://unknown.test/", route("catchall", ""), 200, "catchall"},
+		{"http://app.example.com/api/users", route("catchall", ""), 200, "app"},
+		{"http://app.example.com/other", route("catchall", ""), 200, "catchall"},
+		{"http://www.example.com/other", route("catchall", ""), 200, "wildcard"},
+		{"http://exact.example.com/other", route("catchall", ""), 200, "exact"},
+		{"http://backend.example.com/api", route("catchall", ""), 200, "app"},
+		{"http://backend.example.com/other", route("catchall", ""), 200, "backend"},
+		{"http://app.example.com/other", nil, 404, "Unable to resolve service for path\n"},
+		{"http://backend.example.com/other", nil, 200, "backend"},
+		// Without a route table the controller may not be ready yet
+		{"http://unknown.test/", nil, 503, "Service unavailable\n"},
+	}
+	for _, c := range cases {
+		state.defaultRoute = c.defaultRoute
+		recorder := httptest.NewRecorder()
+		s.handleRequest(recorder, httptest.NewRequest("GET", c.url, nil))
+
+		body, _ := ioutil.ReadAll(recorder.Body)
+		if recorder.Code != c.status || string(body) != c.expected {
+			t.Fatalf("unexpected output for %s %v %s", c.url, recorder.Code, body)
+		}
+	}
+}
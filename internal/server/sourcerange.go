@@ -0,0 +1,31 @@
+package server
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/elijahglover/inbound/internal/controller"
+	"github.com/elijahglover/inbound/internal/helpers"
+)
+
+// allowSourceRange applies route allow and deny lists, responds with 403 and returns false when rejected
+func (s *Server) allowSourceRange(w http.ResponseWriter, req *http.Request, route *controller.RoutePath) bool {
+	sourceRange := route.Options.SourceRange
+	if sourceRange.Allow == nil && sourceRange.Deny == nil {
+		return true
+	}
+
+	clientIP := s.clientIP(req)
+	ip := net.ParseIP(clientIP)
+	denied := helpers.ContainsIP(sourceRange.Deny, ip)
+	if sourceRange.Allow != nil && !helpers.ContainsIP(sourceRange.Allow, ip) {
+		denied = true
+	}
+	if !denied {
+		return true
+	}
+
+	s.logger.Verbosef("Client %s denied access to %s %s", clientIP, route.Ingress, route.Path)
+	s.writeError(w, req, 403, "Forbidden")
+	return false
+}